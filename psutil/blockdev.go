@@ -0,0 +1,286 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// blockQueueProperties lists the files of /sys/block/<dev>/queue exposed
+// as-is in tags of the same name
+var blockQueueProperties = []string{
+	"rotational",
+}
+
+// blockValueLabels describe the numeric properties of a block device, read
+// from /sys/block/<dev>/size and the files of the same name in
+// /sys/block/<dev>/queue, and reported as disk I/O metrics
+var blockValueLabels = map[string]label{
+	"size_bytes": label{
+		description: "size of the device",
+		unit:        unitBytes,
+	},
+	"logical_block_size": label{
+		description: "smallest unit the device can address",
+		unit:        unitBytes,
+	},
+	"physical_block_size": label{
+		description: "smallest unit the device can write without a read-modify-write cycle",
+		unit:        unitBytes,
+	},
+	"nr_requests": label{
+		description: "number of requests which can be queued for the device",
+		unit:        unitCount,
+	},
+	"read_ahead_kb": label{
+		description: "amount of data read ahead on sequential reads",
+		unit:        unitKibibytes,
+	},
+}
+
+// blockDevice holds the names a block device is known by: the kernel name
+// (dm-3, md127, sda1), the name an operator would recognise (the device-mapper
+// or md array name) and the devices it is built on top of, together with the
+// hardware and queue properties of the device: descriptors such as the
+// scheduler are kept as strings, sizes and limits as numbers.
+type blockDevice struct {
+	kernelName   string
+	friendlyName string
+	slaves       []string
+	lvmVG        string
+	lvmLV        string
+	properties   map[string]string
+	values       map[string]uint64
+}
+
+// getBlockDevice resolves a device path as reported in the mount table
+// (e.g. /dev/mapper/vg0-root or /dev/md127) using /sys/class/block, the
+// device-mapper names and the /dev/mapper and /dev/md links. It returns nil
+// when the device is not a block device (tmpfs, overlay, ...).
+func getBlockDevice(device string) *blockDevice {
+	kname := blockKernelName(device)
+	if kname == "" {
+		return nil
+	}
+	return getBlockDeviceByKernelName(kname)
+}
+
+// getBlockDeviceByKernelName resolves a kernel device name as listed in
+// /proc/diskstats (e.g. dm-3 or md127), or returns nil when it is not in
+// /sys/class/block
+func getBlockDeviceByKernelName(kname string) *blockDevice {
+	if _, err := os.Stat(hostSys("class", "block", kname)); err != nil {
+		return nil
+	}
+	bd := &blockDevice{
+		kernelName:   kname,
+		friendlyName: kname,
+		slaves:       readDirNames(hostSys("class", "block", kname, "slaves")),
+		properties:   getBlockProperties(kname),
+		values:       getBlockValues(kname),
+	}
+	if dmName := readSysfsString(hostSys("class", "block", kname, "dm", "name")); dmName != "" {
+		bd.friendlyName = dmName
+		if strings.HasPrefix(readSysfsString(hostSys("class", "block", kname, "dm", "uuid")), "LVM-") {
+			bd.lvmVG, bd.lvmLV = splitLVMName(dmName)
+		}
+	} else if mdName, ok := mdArrayNames()[kname]; ok {
+		bd.friendlyName = mdName
+	}
+	return bd
+}
+
+// tags returns the resolved names in the form attached to metrics
+func (bd *blockDevice) tags() map[string]string {
+	tags := map[string]string{
+		"kernel_name":   bd.kernelName,
+		"friendly_name": bd.friendlyName,
+	}
+	if len(bd.slaves) > 0 {
+		tags["slaves"] = strings.Join(bd.slaves, ",")
+	}
+	if bd.lvmVG != "" {
+		tags["lvm_vg"] = bd.lvmVG
+		tags["lvm_lv"] = bd.lvmLV
+	}
+	for k, v := range bd.properties {
+		tags[k] = v
+	}
+	return tags
+}
+
+// getBlockProperties reads the descriptive properties of the request queue
+// of the device
+func getBlockProperties(kname string) map[string]string {
+	props := map[string]string{}
+	queue := blockQueueDir(kname)
+	if queue == "" {
+		return props
+	}
+	for _, name := range blockQueueProperties {
+		if value := readSysfsString(filepath.Join(queue, name)); value != "" {
+			props[name] = value
+		}
+	}
+	if scheduler := activeScheduler(readSysfsString(filepath.Join(queue, "scheduler"))); scheduler != "" {
+		props["scheduler"] = scheduler
+	}
+	if discard, err := strconv.ParseUint(readSysfsString(filepath.Join(queue, "discard_max_bytes")), 10, 64); err == nil {
+		props["discard"] = strconv.FormatBool(discard > 0)
+	}
+	return props
+}
+
+// getBlockValues reads the size of the device and the numeric properties of
+// its request queue
+func getBlockValues(kname string) map[string]uint64 {
+	values := map[string]uint64{}
+	if sectors, err := strconv.ParseUint(readSysfsString(hostSys("class", "block", kname, "size")), 10, 64); err == nil {
+		// size is always reported in 512 byte sectors, regardless of the
+		// logical block size of the device
+		values["size_bytes"] = sectors * 512
+	}
+	queue := blockQueueDir(kname)
+	if queue == "" {
+		return values
+	}
+	for name := range blockValueLabels {
+		if value, err := strconv.ParseUint(readSysfsString(filepath.Join(queue, name)), 10, 64); err == nil {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// blockQueueDir returns the request queue directory of the device, or an
+// empty string if it has none; partitions share the queue of the disk they
+// belong to.
+func blockQueueDir(kname string) string {
+	dev := hostSys("class", "block", kname)
+	queue := filepath.Join(dev, "queue")
+	if _, err := os.Stat(filepath.Join(dev, "partition")); err == nil {
+		resolved, err := filepath.EvalSymlinks(dev)
+		if err != nil {
+			return ""
+		}
+		queue = filepath.Join(filepath.Dir(resolved), "queue")
+	}
+	if _, err := os.Stat(queue); err != nil {
+		return ""
+	}
+	return queue
+}
+
+// activeScheduler picks the selected I/O scheduler from the content of the
+// queue/scheduler file, e.g. "noop deadline [cfq]"
+func activeScheduler(schedulers string) string {
+	for _, s := range strings.Fields(schedulers) {
+		if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+			return strings.Trim(s, "[]")
+		}
+	}
+	// devices without a choice (e.g. "none") report it without brackets
+	if fields := strings.Fields(schedulers); len(fields) == 1 {
+		return fields[0]
+	}
+	return ""
+}
+
+// blockKernelName follows /dev symlinks (/dev/mapper/*, /dev/md/*,
+// /dev/disk/by-*) down to the kernel device name. Device-mapper nodes which
+// are not symlinks are looked up by their name in /sys/class/block/dm-*/dm.
+func blockKernelName(device string) string {
+	if !strings.HasPrefix(device, "/dev/") {
+		return ""
+	}
+	path := hostDev(strings.TrimPrefix(device, "/dev/"))
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	kname := filepath.Base(path)
+	if _, err := os.Stat(hostSys("class", "block", kname)); err == nil {
+		return kname
+	}
+	if strings.HasPrefix(device, "/dev/mapper/") {
+		dms, _ := filepath.Glob(hostSys("class", "block", "dm-*"))
+		for _, dm := range dms {
+			if readSysfsString(filepath.Join(dm, "dm", "name")) == kname {
+				return filepath.Base(dm)
+			}
+		}
+	}
+	return kname
+}
+
+// mdArrayNames maps md kernel names to the array names linked in /dev/md
+func mdArrayNames() map[string]string {
+	names := map[string]string{}
+	for _, name := range readDirNames(hostDev("md")) {
+		target, err := os.Readlink(hostDev("md", name))
+		if err != nil {
+			continue
+		}
+		names[filepath.Base(target)] = name
+	}
+	return names
+}
+
+// splitLVMName splits a device-mapper name of a logical volume into volume
+// group and logical volume; dashes inside either name are doubled by LVM.
+func splitLVMName(name string) (string, string) {
+	for i := 0; i < len(name); i++ {
+		if name[i] != '-' {
+			continue
+		}
+		if i+1 < len(name) && name[i+1] == '-' {
+			i++
+			continue
+		}
+		return strings.Replace(name[:i], "--", "-", -1), strings.Replace(name[i+1:], "--", "-", -1)
+	}
+	return strings.Replace(name, "--", "-", -1), ""
+}
+
+// readSysfsString returns the trimmed content of a single value sysfs file or
+// an empty string if it cannot be read
+func readSysfsString(path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// readDirNames returns the sorted entry names of a directory, or nil if the
+// directory cannot be read
+func readDirNames(path string) []string {
+	infos, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names
+}
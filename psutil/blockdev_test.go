@@ -0,0 +1,138 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSplitLVMName(t *testing.T) {
+	Convey("Split device-mapper names of logical volumes", t, func() {
+		vg, lv := splitLVMName("vg0-root")
+		So(vg, ShouldEqual, "vg0")
+		So(lv, ShouldEqual, "root")
+
+		vg, lv = splitLVMName("vg--data-my--volume")
+		So(vg, ShouldEqual, "vg-data")
+		So(lv, ShouldEqual, "my-volume")
+
+		vg, lv = splitLVMName("cryptswap")
+		So(vg, ShouldEqual, "cryptswap")
+		So(lv, ShouldEqual, "")
+	})
+}
+
+func TestBlockDeviceNames(t *testing.T) {
+	Convey("Resolve block device names", t, func() {
+		os.Setenv("HOST_SYS", "testdata/sys")
+		os.Setenv("HOST_DEV", "testdata/dev")
+		defer os.Unsetenv("HOST_SYS")
+		defer os.Unsetenv("HOST_DEV")
+
+		Convey("from device paths of the mount table", func() {
+			So(blockKernelName("/dev/sda1"), ShouldEqual, "sda1")
+			So(blockKernelName("/dev/mapper/vg--data-root"), ShouldEqual, "dm-0")
+			// device-mapper nodes which are not symlinks
+			So(blockKernelName("/dev/mapper/cryptswap"), ShouldEqual, "dm-1")
+			So(blockKernelName("/dev/md/data"), ShouldEqual, "md127")
+			So(blockKernelName("tmpfs"), ShouldEqual, "")
+		})
+
+		Convey("of md arrays", func() {
+			So(mdArrayNames(), ShouldResemble, map[string]string{"md127": "data"})
+		})
+
+		Convey("into friendly names and slaves", func() {
+			bd := getBlockDevice("/dev/mapper/vg--data-root")
+			So(bd, ShouldNotBeNil)
+			tags := bd.tags()
+			So(tags["kernel_name"], ShouldEqual, "dm-0")
+			So(tags["friendly_name"], ShouldEqual, "vg--data-root")
+			So(tags["slaves"], ShouldEqual, "sda1")
+			So(tags["lvm_vg"], ShouldEqual, "vg-data")
+			So(tags["lvm_lv"], ShouldEqual, "root")
+
+			bd = getBlockDeviceByKernelName("dm-1")
+			So(bd.friendlyName, ShouldEqual, "cryptswap")
+			So(bd.lvmVG, ShouldEqual, "")
+
+			bd = getBlockDeviceByKernelName("md127")
+			So(bd.friendlyName, ShouldEqual, "data")
+
+			So(getBlockDevice("tmpfs"), ShouldBeNil)
+			So(getBlockDeviceByKernelName("sdz"), ShouldBeNil)
+		})
+	})
+}
+
+func TestActiveScheduler(t *testing.T) {
+	Convey("Pick the selected I/O scheduler", t, func() {
+		So(activeScheduler("noop deadline [cfq]"), ShouldEqual, "cfq")
+		So(activeScheduler("[mq-deadline] kyber bfq none"), ShouldEqual, "mq-deadline")
+		So(activeScheduler("none"), ShouldEqual, "none")
+		So(activeScheduler(""), ShouldEqual, "")
+	})
+}
+
+func TestBlockProperties(t *testing.T) {
+	Convey("Read block device properties", t, func() {
+		os.Setenv("HOST_SYS", "testdata/sys")
+		defer os.Unsetenv("HOST_SYS")
+
+		Convey("of a disk", func() {
+			So(getBlockProperties("sda"), ShouldResemble, map[string]string{
+				"rotational": "1",
+				"scheduler":  "cfq",
+				"discard":    "false",
+			})
+			So(getBlockValues("sda"), ShouldResemble, map[string]uint64{
+				"size_bytes":          1953525168 * 512,
+				"logical_block_size":  512,
+				"physical_block_size": 4096,
+				"nr_requests":         128,
+				"read_ahead_kb":       128,
+			})
+		})
+
+		Convey("of a partition, from the queue of its disk", func() {
+			So(getBlockProperties("sda1")["scheduler"], ShouldEqual, "cfq")
+			values := getBlockValues("sda1")
+			So(values["size_bytes"], ShouldEqual, uint64(1953523120*512))
+			So(values["physical_block_size"], ShouldEqual, uint64(4096))
+		})
+
+		Convey("of an md array supporting discard", func() {
+			props := getBlockProperties("md127")
+			So(props["discard"], ShouldEqual, "true")
+			So(props["scheduler"], ShouldEqual, "none")
+			So(getBlockValues("md127")["read_ahead_kb"], ShouldEqual, uint64(6144))
+		})
+
+		Convey("of an unknown device", func() {
+			So(getBlockProperties("sdz"), ShouldBeEmpty)
+			So(getBlockValues("sdz"), ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,184 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// fragmentationOrder is the allocation order the fragmentation index is
+// computed for; it is PAGE_ALLOC_COSTLY_ORDER, above which the kernel
+// considers allocations hard to satisfy (e.g. jumbo frame buffers of NICs)
+const fragmentationOrder = 3
+
+// buddyZone holds the number of free blocks of each order of a memory zone
+type buddyZone struct {
+	node   string
+	zone   string
+	counts []uint64
+}
+
+func buddyinfo(nss []plugin.Namespace) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "buddyinfo")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(hostProc("buddyinfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zones, err := parseBuddyinfo(f)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, ns := range nss {
+		fragmentation := len(ns) == 6 && ns[5].Value == "fragmentation_index"
+		if !fragmentation && (len(ns) != 7 || ns[6].Value != "free_blocks") {
+			return nil, fmt.Errorf("Requested buddyinfo statistic %s is not available", strings.Join(ns.Strings()[3:], "/"))
+		}
+		for _, zone := range zones {
+			if ns[3].Value != "*" && ns[3].Value != zone.node {
+				continue
+			}
+			if ns[4].Value != "*" && ns[4].Value != zone.zone {
+				continue
+			}
+			dyn := make([]plugin.NamespaceElement, len(ns))
+			copy(dyn, ns)
+			dyn[3].Value = zone.node
+			dyn[4].Value = zone.zone
+			if fragmentation {
+				results = append(results, plugin.Metric{
+					Namespace: dyn,
+					Data:      fragmentationIndex(zone.counts, fragmentationOrder),
+					Timestamp: t,
+					Unit:      unitRatio,
+				})
+				continue
+			}
+			for order, count := range zone.counts {
+				name := fmt.Sprintf("order%d", order)
+				if ns[5].Value != "*" && ns[5].Value != name {
+					continue
+				}
+				orderDyn := make([]plugin.NamespaceElement, len(dyn))
+				copy(orderDyn, dyn)
+				orderDyn[5].Value = name
+				results = append(results, plugin.Metric{
+					Namespace: orderDyn,
+					Data:      count,
+					Timestamp: t,
+					Unit:      unitCount,
+				})
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// parseBuddyinfo parses /proc/buddyinfo, e.g.
+// "Node 0, zone   Normal   4356   1534    358 ...", whose columns are the
+// numbers of free blocks of 2^order pages, starting with order 0
+func parseBuddyinfo(r io.Reader) ([]buddyZone, error) {
+	zones := []buddyZone{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[0] != "Node" || fields[2] != "zone" {
+			continue
+		}
+		zone := buddyZone{
+			node: "node" + strings.TrimSuffix(fields[1], ","),
+			zone: fields[3],
+		}
+		for _, field := range fields[4:] {
+			count, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid line in /proc/buddyinfo: %s", line)
+			}
+			zone.counts = append(zone.counts, count)
+		}
+		zones = append(zones, zone)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// fragmentationIndex returns the unusable free space index of a zone for
+// allocations of the given order: the fraction of free pages which are in
+// blocks too small to satisfy them, from 0 (no fragmentation) to 1 (no block
+// is large enough, or there is no free memory at all)
+func fragmentationIndex(counts []uint64, order int) float64 {
+	var free, usable uint64
+	for o, count := range counts {
+		pages := count << uint(o)
+		free += pages
+		if o >= order {
+			usable += pages
+		}
+	}
+	if free == 0 {
+		return 1
+	}
+	return float64(free-usable) / float64(free)
+}
+
+func getBuddyinfoMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getBuddyinfoMetricTypes")
+	mts := []plugin.Metric{}
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	mts = append(mts, plugin.Metric{
+		Namespace: plugin.NewNamespace("intel", "psutil", "buddyinfo").
+			AddDynamicElement("node", "NUMA node (node0, node1, ...)").
+			AddDynamicElement("zone", "memory zone (DMA, DMA32, Normal, ...)").
+			AddDynamicElement("order", "allocation order (order0, order1, ...), blocks of 2^order pages").
+			AddStaticElement("free_blocks"),
+		Description: "number of free blocks of 2^order pages in the zone",
+		Unit:        unitCount,
+	})
+	mts = append(mts, plugin.Metric{
+		Namespace: plugin.NewNamespace("intel", "psutil", "buddyinfo").
+			AddDynamicElement("node", "NUMA node (node0, node1, ...)").
+			AddDynamicElement("zone", "memory zone (DMA, DMA32, Normal, ...)").
+			AddStaticElement("fragmentation_index"),
+		Description: fmt.Sprintf("fraction of free memory of the zone in blocks too small for an allocation of order %d, from 0 to 1", fragmentationOrder),
+		Unit:        unitRatio,
+	})
+	return mts
+}
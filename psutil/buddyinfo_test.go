@@ -0,0 +1,112 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestParseBuddyinfo(t *testing.T) {
+	Convey("Parse /proc/buddyinfo", t, func() {
+		f, err := os.Open("testdata/buddyinfo")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		zones, err := parseBuddyinfo(f)
+		So(err, ShouldBeNil)
+		So(len(zones), ShouldEqual, 4)
+		So(zones[0].node, ShouldEqual, "node0")
+		So(zones[0].zone, ShouldEqual, "DMA")
+		So(zones[2], ShouldResemble, buddyZone{
+			node:   "node0",
+			zone:   "Normal",
+			counts: []uint64{4356, 1534, 358, 160, 74, 32, 10, 6, 6, 3, 16},
+		})
+		So(zones[3].node, ShouldEqual, "node1")
+		So(len(zones[3].counts), ShouldEqual, 11)
+	})
+
+	Convey("Parse invalid /proc/buddyinfo", t, func() {
+		_, err := parseBuddyinfo(strings.NewReader("Node 0, zone Normal 12 x 3\n"))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestFragmentationIndex(t *testing.T) {
+	Convey("Compute the fragmentation index of a zone", t, func() {
+		Convey("with all free memory in large blocks", func() {
+			So(fragmentationIndex([]uint64{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 3}, 3), ShouldEqual, 0.0)
+		})
+
+		Convey("with free memory in small and large blocks", func() {
+			// 4 free pages of order 0 and 1, 8 of order 3
+			So(fragmentationIndex([]uint64{2, 1, 0, 1}, 3), ShouldEqual, 1.0/3)
+		})
+
+		Convey("with a fragmented zone", func() {
+			So(fragmentationIndex([]uint64{88312, 21034, 817, 0}, 3), ShouldEqual, 1.0)
+		})
+
+		Convey("without free memory", func() {
+			So(fragmentationIndex([]uint64{0, 0, 0, 0}, 3), ShouldEqual, 1.0)
+		})
+	})
+}
+
+func TestBuddyinfo(t *testing.T) {
+	Convey("Collect free blocks from /proc/buddyinfo", t, func() {
+		os.Setenv("HOST_PROC", "testdata")
+		defer os.Unsetenv("HOST_PROC")
+
+		Convey("of each order", func() {
+			metrics, err := buddyinfo([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "buddyinfo", "node0", "Normal", "*", "free_blocks"),
+			})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 11)
+			So(metrics[3].Namespace.Strings(), ShouldResemble, []string{"intel", "psutil", "buddyinfo", "node0", "Normal", "order3", "free_blocks"})
+			So(metrics[3].Data, ShouldEqual, uint64(160))
+			So(metrics[3].Unit, ShouldEqual, unitCount)
+		})
+		Convey("as a fragmentation index", func() {
+			metrics, err := buddyinfo([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "buddyinfo", "node0", "DMA", "fragmentation_index"),
+			})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Data, ShouldEqual, 0.0)
+			So(metrics[0].Unit, ShouldEqual, unitRatio)
+		})
+		Convey("without the free_blocks leaf", func() {
+			_, err := buddyinfo([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "buddyinfo", "node0", "Normal", "order3"),
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,480 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// cgroupUnlimited is the threshold above which a cgroup v1 memory limit means
+// no limit; unlimited cgroups report the largest page aligned int64
+const cgroupUnlimited = 1 << 62
+
+var cgroupLabels = map[string]label{
+	"cpu_usage_ns": label{
+		description: "cpu time consumed by tasks of the cgroup",
+		unit:        unitNanoseconds,
+	},
+	"cpu_nr_periods": label{
+		description: "number of enforcement periods of the cpu bandwidth limit which elapsed",
+		unit:        unitCount,
+	},
+	"cpu_nr_throttled": label{
+		description: "number of enforcement periods during which the cgroup was throttled",
+		unit:        unitCount,
+	},
+	"cpu_throttled_ns": label{
+		description: "total time tasks of the cgroup were throttled for",
+		unit:        unitNanoseconds,
+	},
+	"memory_usage_bytes": label{
+		description: "memory used by tasks of the cgroup, including page cache",
+		unit:        unitBytes,
+	},
+	"memory_limit_bytes": label{
+		description: "memory limit of the cgroup, not reported when the cgroup is unlimited",
+		unit:        unitBytes,
+	},
+	"memory_failcnt": label{
+		description: "number of times the memory usage of the cgroup hit its limit",
+		unit:        unitCount,
+	},
+	"memory_oom_events": label{
+		description: "number of tasks of the cgroup killed by the OOM killer",
+		unit:        unitCount,
+	},
+	"io_read_bytes": label{
+		description: "bytes read from block devices by tasks of the cgroup",
+		unit:        unitBytes,
+	},
+	"io_write_bytes": label{
+		description: "bytes written to block devices by tasks of the cgroup",
+		unit:        unitBytes,
+	},
+	"io_read_ops": label{
+		description: "number of read operations issued to block devices by tasks of the cgroup",
+		unit:        unitCount,
+	},
+	"io_write_ops": label{
+		description: "number of write operations issued to block devices by tasks of the cgroup",
+		unit:        unitCount,
+	},
+	"pids_current": label{
+		description: "number of tasks in the cgroup",
+		unit:        unitCount,
+	},
+}
+
+// cgroup holds the statistics of a control group gathered from all
+// hierarchies it appears in, keyed by metric name
+type cgroup struct {
+	path     string
+	version  string
+	values   map[string]uint64
+	pressure map[string]psiStats
+}
+
+// cgroupFilter restricts collected cgroups to the paths matching the include
+// expression and not matching the exclude one
+type cgroupFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// cgroupV1Readers read the files of each cgroup v1 controller
+var cgroupV1Readers = map[string]func(dir string, values map[string]uint64){
+	"cpuacct": readCgroupV1CPUAcct,
+	"cpu":     readCgroupV1CPU,
+	"memory":  readCgroupV1Memory,
+	"blkio":   readCgroupV1Blkio,
+	"pids":    readCgroupPids,
+}
+
+func cgroupStats(nss []plugin.Namespace, cfg plugin.Config) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "cgroupStats")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	filter, err := getCgroupFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cgroups, err := getCgroups(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, ns := range nss {
+		if len(ns) == 7 {
+			pressureMts, err := cgroupPressure(ns, cgroups, t)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, pressureMts...)
+			continue
+		}
+		metricName := ns.Element(len(ns) - 1).Value
+		if _, ok := cgroupLabels[metricName]; !ok {
+			return nil, fmt.Errorf("Requested cgroup statistic %s is not available", metricName)
+		}
+		for _, cg := range cgroups {
+			if ns[3].Value != "*" && ns[3].Value != cg.path {
+				continue
+			}
+			value, ok := cg.values[metricName]
+			if !ok {
+				continue
+			}
+			dyn := make([]plugin.NamespaceElement, len(ns))
+			copy(dyn, ns)
+			dyn[3].Value = cg.path
+			results = append(results, plugin.Metric{
+				Namespace: dyn,
+				Data:      value,
+				Tags:      cg.tags(),
+				Timestamp: t,
+				Unit:      cgroupLabels[metricName].unit,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// tags returns the cgroup version together with the container, pod and
+// systemd unit identity derived from the cgroup path
+func (c *cgroup) tags() map[string]string {
+	tags := getContainerIdentity(c.path).tags()
+	tags["cgroup_version"] = c.version
+	return tags
+}
+
+func getCgroupFilter(cfg plugin.Config) (*cgroupFilter, error) {
+	filter := &cgroupFilter{}
+	for option, dest := range map[string]**regexp.Regexp{
+		"include": &filter.include,
+		"exclude": &filter.exclude,
+	} {
+		expr, err := cfg.GetString(option)
+		if err != nil || expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s expression %q: %v", option, expr, err)
+		}
+		*dest = re
+	}
+	return filter, nil
+}
+
+func (f *cgroupFilter) match(path string) bool {
+	if f.include != nil && !f.include.MatchString(path) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(path) {
+		return false
+	}
+	return true
+}
+
+// getCgroups walks the cgroup v2 unified tree and the v1 hierarchies of
+// /sys/fs/cgroup and returns the cgroups below the root sorted by path. On
+// hybrid hosts, where the unified tree is mounted at /sys/fs/cgroup/unified,
+// statistics of controllers attached to v1 hierarchies come from them.
+func getCgroups(filter *cgroupFilter) ([]*cgroup, error) {
+	cgroups := map[string]*cgroup{}
+	root := hostSys("fs", "cgroup")
+	if unified := cgroupV2Root(); unified != "" {
+		if err := walkCgroups(unified, "2", filter, cgroups, readCgroupV2); err != nil {
+			return nil, err
+		}
+		if unified == root {
+			// pure cgroup v2 host
+			return sortedCgroups(cgroups), nil
+		}
+	}
+	for controller, read := range cgroupV1Readers {
+		// cpu and cpuacct are usually symlinks to a co-mounted cpu,cpuacct
+		dir, err := filepath.EvalSymlinks(filepath.Join(root, controller))
+		if err != nil {
+			continue
+		}
+		if err := walkCgroups(dir, "1", filter, cgroups, read); err != nil {
+			return nil, err
+		}
+	}
+	return sortedCgroups(cgroups), nil
+}
+
+// cgroupV2Root returns the mount point of the cgroup v2 unified tree, which
+// is /sys/fs/cgroup itself on pure cgroup v2 hosts, or an empty string when
+// the host only uses cgroup v1
+func cgroupV2Root() string {
+	root := hostSys("fs", "cgroup")
+	for _, dir := range []string{root, filepath.Join(root, "unified")} {
+		if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
+
+func walkCgroups(root, version string, filter *cgroupFilter, cgroups map[string]*cgroup, read func(string, map[string]uint64)) error {
+	return filepath.Walk(root, func(dir string, info os.FileInfo, err error) error {
+		if err != nil {
+			// cgroups may disappear while the tree is walked
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() || dir == root {
+			return nil
+		}
+		path := "/" + filepath.ToSlash(strings.TrimPrefix(dir, root+string(filepath.Separator)))
+		if !filter.match(path) {
+			return nil
+		}
+		cg, ok := cgroups[path]
+		if !ok {
+			cg = &cgroup{path: path, version: version, values: map[string]uint64{}}
+			cgroups[path] = cg
+		}
+		if version == "1" {
+			// v1 controllers take precedence over an unified tree without
+			// them on hybrid hosts
+			cg.version = version
+		}
+		read(dir, cg.values)
+		if version == "2" {
+			cg.pressure = readCgroupPressure(dir)
+		}
+		return nil
+	})
+}
+
+func sortedCgroups(cgroups map[string]*cgroup) []*cgroup {
+	paths := make([]string, 0, len(cgroups))
+	for path := range cgroups {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	sorted := make([]*cgroup, 0, len(paths))
+	for _, path := range paths {
+		sorted = append(sorted, cgroups[path])
+	}
+	return sorted
+}
+
+func readCgroupV1CPUAcct(dir string, values map[string]uint64) {
+	setCgroupValue(values, "cpu_usage_ns", filepath.Join(dir, "cpuacct.usage"))
+}
+
+func readCgroupV1CPU(dir string, values map[string]uint64) {
+	stat := readCgroupKeyedFile(filepath.Join(dir, "cpu.stat"))
+	copyCgroupValues(values, stat, map[string]string{
+		"nr_periods":     "cpu_nr_periods",
+		"nr_throttled":   "cpu_nr_throttled",
+		"throttled_time": "cpu_throttled_ns",
+	}, 1)
+}
+
+func readCgroupV1Memory(dir string, values map[string]uint64) {
+	setCgroupValue(values, "memory_usage_bytes", filepath.Join(dir, "memory.usage_in_bytes"))
+	setCgroupValue(values, "memory_failcnt", filepath.Join(dir, "memory.failcnt"))
+	if limit, ok := readCgroupUint(filepath.Join(dir, "memory.limit_in_bytes")); ok && limit < cgroupUnlimited {
+		values["memory_limit_bytes"] = limit
+	}
+	// oom_kill is reported since Linux 4.13
+	oom := readCgroupKeyedFile(filepath.Join(dir, "memory.oom_control"))
+	copyCgroupValues(values, oom, map[string]string{"oom_kill": "memory_oom_events"}, 1)
+}
+
+func readCgroupV1Blkio(dir string, values map[string]uint64) {
+	for file, metrics := range map[string][2]string{
+		"blkio.throttle.io_service_bytes": {"io_read_bytes", "io_write_bytes"},
+		"blkio.throttle.io_serviced":      {"io_read_ops", "io_write_ops"},
+	} {
+		f, err := os.Open(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		var read, write uint64
+		// lines are "<major>:<minor> <Read|Write|Sync|Async|Total> <value>"
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 3 {
+				continue
+			}
+			value, err := strconv.ParseUint(fields[2], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch fields[1] {
+			case "Read":
+				read += value
+			case "Write":
+				write += value
+			}
+		}
+		f.Close()
+		values[metrics[0]] = read
+		values[metrics[1]] = write
+	}
+}
+
+func readCgroupPids(dir string, values map[string]uint64) {
+	setCgroupValue(values, "pids_current", filepath.Join(dir, "pids.current"))
+}
+
+func readCgroupV2(dir string, values map[string]uint64) {
+	stat := readCgroupKeyedFile(filepath.Join(dir, "cpu.stat"))
+	copyCgroupValues(values, stat, map[string]string{
+		"nr_periods":   "cpu_nr_periods",
+		"nr_throttled": "cpu_nr_throttled",
+	}, 1)
+	copyCgroupValues(values, stat, map[string]string{
+		"usage_usec":     "cpu_usage_ns",
+		"throttled_usec": "cpu_throttled_ns",
+	}, 1000)
+
+	setCgroupValue(values, "memory_usage_bytes", filepath.Join(dir, "memory.current"))
+	// memory.max is "max" when the cgroup is unlimited
+	setCgroupValue(values, "memory_limit_bytes", filepath.Join(dir, "memory.max"))
+	events := readCgroupKeyedFile(filepath.Join(dir, "memory.events"))
+	copyCgroupValues(values, events, map[string]string{
+		"max":      "memory_failcnt",
+		"oom_kill": "memory_oom_events",
+	}, 1)
+
+	if f, err := os.Open(filepath.Join(dir, "io.stat")); err == nil {
+		totals := map[string]uint64{}
+		// lines are "<major>:<minor> rbytes=<value> wbytes=<value> ..."
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			for _, field := range fields[1:] {
+				kv := strings.SplitN(field, "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				if value, err := strconv.ParseUint(kv[1], 10, 64); err == nil {
+					totals[kv[0]] += value
+				}
+			}
+		}
+		f.Close()
+		for key, metric := range map[string]string{
+			"rbytes": "io_read_bytes",
+			"wbytes": "io_write_bytes",
+			"rios":   "io_read_ops",
+			"wios":   "io_write_ops",
+		} {
+			values[metric] = totals[key]
+		}
+	}
+
+	readCgroupPids(dir, values)
+}
+
+// readCgroupUint reads a file holding a single number; files which do not
+// exist, because the controller is not enabled, and values such as "max" are
+// reported as not available
+func readCgroupUint(path string) (uint64, bool) {
+	value, err := strconv.ParseUint(readSysfsString(path), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+func setCgroupValue(values map[string]uint64, metric, path string) {
+	if value, ok := readCgroupUint(path); ok {
+		values[metric] = value
+	}
+}
+
+// readCgroupKeyedFile reads a flat keyed file of "<key> <value>" lines, such
+// as cpu.stat or memory.events
+func readCgroupKeyedFile(path string) map[string]uint64 {
+	keyed := map[string]uint64{}
+	f, err := os.Open(path)
+	if err != nil {
+		return keyed
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			keyed[fields[0]] = value
+		}
+	}
+	return keyed
+}
+
+// copyCgroupValues copies the keys of a flat keyed file present in keys to
+// their metric, scaled by factor
+func copyCgroupValues(values, keyed map[string]uint64, keys map[string]string, factor uint64) {
+	for key, metric := range keys {
+		if value, ok := keyed[key]; ok {
+			values[metric] = value * factor
+		}
+	}
+}
+
+func getCgroupMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getCgroupMetricTypes")
+	mts := []plugin.Metric{}
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	for k, label := range cgroupLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace: plugin.NewNamespace("intel", "psutil", "cgroup").
+				AddDynamicElement("cgroup_path", "path of the cgroup relative to the root of its hierarchy").
+				AddStaticElement(k),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
+	return mts
+}
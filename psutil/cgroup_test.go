@@ -0,0 +1,124 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestGetCgroupsV1(t *testing.T) {
+	Convey("Read cgroup v1 hierarchies", t, func() {
+		os.Setenv("HOST_SYS", "testdata/cgroup_v1")
+		defer os.Unsetenv("HOST_SYS")
+
+		cgroups, err := getCgroups(&cgroupFilter{})
+		So(err, ShouldBeNil)
+		So(len(cgroups), ShouldEqual, 3)
+		So(cgroups[0].path, ShouldEqual, "/docker")
+		So(cgroups[0].version, ShouldEqual, "1")
+		// the unlimited memory limit is not reported
+		So(cgroups[0].values, ShouldNotContainKey, "memory_limit_bytes")
+		So(cgroups[1].path, ShouldEqual, "/docker/abc")
+		So(cgroups[1].values, ShouldResemble, map[string]uint64{
+			"cpu_usage_ns":       2500000000,
+			"cpu_nr_periods":     120,
+			"cpu_nr_throttled":   7,
+			"cpu_throttled_ns":   350000000,
+			"memory_usage_bytes": 104857600,
+			"memory_limit_bytes": 268435456,
+			"memory_failcnt":     3,
+			"memory_oom_events":  1,
+			"io_read_bytes":      5120,
+			"io_write_bytes":     8192,
+			"io_read_ops":        3,
+			"io_write_ops":       3,
+			"pids_current":       4,
+		})
+		So(cgroups[2].path, ShouldEqual, "/system.slice")
+
+		Convey("filtered by include and exclude expressions", func() {
+			filter, err := getCgroupFilter(plugin.Config{"include": "^/docker", "exclude": "^/docker$"})
+			So(err, ShouldBeNil)
+			cgroups, err := getCgroups(filter)
+			So(err, ShouldBeNil)
+			So(len(cgroups), ShouldEqual, 1)
+			So(cgroups[0].path, ShouldEqual, "/docker/abc")
+		})
+	})
+}
+
+func TestGetCgroupsV2(t *testing.T) {
+	Convey("Read the cgroup v2 unified tree", t, func() {
+		os.Setenv("HOST_SYS", "testdata/cgroup_v2")
+		defer os.Unsetenv("HOST_SYS")
+
+		cgroups, err := getCgroups(&cgroupFilter{})
+		So(err, ShouldBeNil)
+		So(len(cgroups), ShouldEqual, 3)
+		So(cgroups[1].path, ShouldEqual, "/system.slice/sshd.service")
+		So(cgroups[1].version, ShouldEqual, "2")
+		So(cgroups[1].values, ShouldResemble, map[string]uint64{
+			"cpu_usage_ns":       1500000,
+			"cpu_nr_periods":     40,
+			"cpu_nr_throttled":   2,
+			"cpu_throttled_ns":   250000,
+			"memory_usage_bytes": 8388608,
+			"memory_limit_bytes": 536870912,
+			"memory_failcnt":     5,
+			"memory_oom_events":  1,
+			"io_read_bytes":      5120,
+			"io_write_bytes":     8192,
+			"io_read_ops":        3,
+			"io_write_ops":       3,
+			"pids_current":       2,
+		})
+		So(cgroups[2].path, ShouldEqual, "/user.slice")
+		So(cgroups[2].values, ShouldNotContainKey, "memory_limit_bytes")
+
+		Convey("and collect requested metrics", func() {
+			nss := []plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "cgroup", "*", "pids_current"),
+				plugin.NewNamespace("intel", "psutil", "cgroup", "/user.slice", "memory_usage_bytes"),
+			}
+			metrics, err := cgroupStats(nss, plugin.Config{})
+			So(err, ShouldBeNil)
+			So(len(metrics), ShouldEqual, 3)
+			So(metrics[0].Namespace.Strings()[3], ShouldEqual, "/system.slice/sshd.service")
+			So(metrics[0].Tags["cgroup_version"], ShouldEqual, "2")
+			So(metrics[0].Tags["systemd_unit"], ShouldEqual, "sshd.service")
+			So(metrics[2].Data, ShouldEqual, uint64(16777216))
+			So(metrics[2].Unit, ShouldEqual, unitBytes)
+		})
+
+		Convey("and fail on an invalid expression", func() {
+			_, err := cgroupStats([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "cgroup", "*", "pids_current"),
+			}, plugin.Config{"include": "["})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,208 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// cgroupPressureResources lists the resources cgroup v2 reports pressure
+// stall information for, in <resource>.pressure files
+var cgroupPressureResources = []string{"cpu", "memory", "io"}
+
+// cgroupPressureKinds describes the lines of a pressure file: "some" is
+// the share of time at least one task was stalled, "full" the share of time
+// all non-idle tasks were stalled at once
+var cgroupPressureKinds = map[string]string{
+	"some": "at least one task",
+	"full": "all non-idle tasks",
+}
+
+var cgroupPressureLabels = map[string]label{
+	"avg10": label{
+		description: "over the last 10 seconds",
+		unit:        unitPercent,
+	},
+	"avg60": label{
+		description: "over the last 60 seconds",
+		unit:        unitPercent,
+	},
+	"avg300": label{
+		description: "over the last 300 seconds",
+		unit:        unitPercent,
+	},
+	"total": label{
+		description: "since the cgroup was created",
+		unit:        unitMicroseconds,
+	},
+}
+
+// psiStats holds a line of a pressure file, e.g.
+// "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+type psiStats struct {
+	avg10  float64
+	avg60  float64
+	avg300 float64
+	total  uint64
+}
+
+// cgroupPressure returns the pressure stall metric requested with ns,
+// intel/psutil/cgroup/<path>/<resource>_pressure/<kind>/<field>, for each
+// matching cgroup
+func cgroupPressure(ns plugin.Namespace, cgroups []*cgroup, t time.Time) ([]plugin.Metric, error) {
+	resource := strings.TrimSuffix(ns[4].Value, "_pressure")
+	kind := ns[5].Value
+	field := ns[6].Value
+	_, validKind := cgroupPressureKinds[kind]
+	_, validField := cgroupPressureLabels[field]
+	if !validKind || !validField || !isCgroupPressureResource(ns[4].Value) {
+		return nil, fmt.Errorf("Requested cgroup statistic %s is not available", strings.Join(ns.Strings()[4:], "/"))
+	}
+	results := []plugin.Metric{}
+	for _, cg := range cgroups {
+		if ns[3].Value != "*" && ns[3].Value != cg.path {
+			continue
+		}
+		stats, ok := cg.pressure[resource+"/"+kind]
+		if !ok {
+			continue
+		}
+		var value interface{}
+		switch field {
+		case "avg10":
+			value = stats.avg10
+		case "avg60":
+			value = stats.avg60
+		case "avg300":
+			value = stats.avg300
+		case "total":
+			value = stats.total
+		}
+		dyn := make([]plugin.NamespaceElement, len(ns))
+		copy(dyn, ns)
+		dyn[3].Value = cg.path
+		results = append(results, plugin.Metric{
+			Namespace: dyn,
+			Data:      value,
+			Tags:      cg.tags(),
+			Timestamp: t,
+			Unit:      cgroupPressureLabels[field].unit,
+		})
+	}
+	return results, nil
+}
+
+func isCgroupPressureResource(name string) bool {
+	for _, resource := range cgroupPressureResources {
+		if name == resource+"_pressure" {
+			return true
+		}
+	}
+	return false
+}
+
+// readCgroupPressure reads the pressure files of a cgroup v2 directory,
+// keyed by <resource>/<kind>; the files are missing when the kernel is
+// built without CONFIG_PSI or booted with psi=0
+func readCgroupPressure(dir string) map[string]psiStats {
+	pressure := map[string]psiStats{}
+	for _, resource := range cgroupPressureResources {
+		f, err := os.Open(filepath.Join(dir, resource+".pressure"))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			kind, stats, err := parsePSILine(scanner.Text())
+			if err != nil {
+				continue
+			}
+			pressure[resource+"/"+kind] = stats
+		}
+		f.Close()
+	}
+	return pressure
+}
+
+func parsePSILine(line string) (string, psiStats, error) {
+	stats := psiStats{}
+	fields := strings.Fields(line)
+	if len(fields) != 5 {
+		return "", stats, fmt.Errorf("Invalid pressure line: %s", line)
+	}
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return "", stats, fmt.Errorf("Invalid pressure line: %s", line)
+		}
+		var err error
+		switch kv[0] {
+		case "avg10":
+			stats.avg10, err = strconv.ParseFloat(kv[1], 64)
+		case "avg60":
+			stats.avg60, err = strconv.ParseFloat(kv[1], 64)
+		case "avg300":
+			stats.avg300, err = strconv.ParseFloat(kv[1], 64)
+		case "total":
+			stats.total, err = strconv.ParseUint(kv[1], 10, 64)
+		}
+		if err != nil {
+			return "", stats, fmt.Errorf("Invalid pressure line: %s", line)
+		}
+	}
+	return fields[0], stats, nil
+}
+
+func getCgroupPressureMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getCgroupPressureMetricTypes")
+	mts := []plugin.Metric{}
+	// pressure stall information is only available per cgroup on cgroup v2
+	if runtime.GOOS != "linux" || cgroupV2Root() == "" {
+		return mts
+	}
+	for _, resource := range cgroupPressureResources {
+		for kind, tasks := range cgroupPressureKinds {
+			for field, label := range cgroupPressureLabels {
+				description := fmt.Sprintf("percentage of time %s of the cgroup stalled on %s %s", tasks, resource, label.description)
+				if field == "total" {
+					description = fmt.Sprintf("total time %s of the cgroup stalled on %s %s", tasks, resource, label.description)
+				}
+				mts = append(mts, plugin.Metric{
+					Namespace: plugin.NewNamespace("intel", "psutil", "cgroup").
+						AddDynamicElement("cgroup_path", "path of the cgroup relative to the root of its hierarchy").
+						AddStaticElement(resource + "_pressure").
+						AddStaticElement(kind).
+						AddStaticElement(field),
+					Description: description,
+					Unit:        label.unit,
+				})
+			}
+		}
+	}
+	return mts
+}
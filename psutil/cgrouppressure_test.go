@@ -0,0 +1,82 @@
+//
+//go:build small
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestParsePSILine(t *testing.T) {
+	Convey("Parse a line of a pressure file", t, func() {
+		kind, stats, err := parsePSILine("some avg10=12.50 avg60=6.10 avg300=2.05 total=98765432")
+		So(err, ShouldBeNil)
+		So(kind, ShouldEqual, "some")
+		So(stats, ShouldResemble, psiStats{avg10: 12.5, avg60: 6.1, avg300: 2.05, total: 98765432})
+
+		_, _, err = parsePSILine("some avg10=x avg60=6.10 avg300=2.05 total=98765432")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestCgroupPressure(t *testing.T) {
+	Convey("Collect cgroup pressure stall information", t, func() {
+		os.Setenv("HOST_SYS", "testdata/cgroup_v2")
+		defer os.Unsetenv("HOST_SYS")
+
+		So(len(getCgroupPressureMetricTypes()), ShouldEqual, len(cgroupPressureResources)*len(cgroupPressureKinds)*len(cgroupPressureLabels))
+
+		nss := []plugin.Namespace{
+			plugin.NewNamespace("intel", "psutil", "cgroup", "*", "memory_pressure", "full", "avg10"),
+			plugin.NewNamespace("intel", "psutil", "cgroup", "*", "cpu_pressure", "some", "total"),
+		}
+		metrics, err := cgroupStats(nss, plugin.Config{})
+		So(err, ShouldBeNil)
+		So(len(metrics), ShouldEqual, 3)
+		So(metrics[0].Namespace.Strings()[3], ShouldEqual, "/system.slice/sshd.service")
+		So(metrics[0].Data, ShouldEqual, 10.0)
+		So(metrics[0].Unit, ShouldEqual, unitPercent)
+		So(metrics[1].Data, ShouldEqual, uint64(1234567))
+		So(metrics[1].Unit, ShouldEqual, unitMicroseconds)
+		So(metrics[2].Namespace.Strings()[3], ShouldEqual, "/user.slice")
+		So(metrics[2].Data, ShouldEqual, uint64(100))
+
+		Convey("and fail on an unknown resource", func() {
+			_, err := cgroupStats([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "cgroup", "*", "net_pressure", "some", "avg10"),
+			}, plugin.Config{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Do not advertise pressure metrics on cgroup v1", t, func() {
+		os.Setenv("HOST_SYS", "testdata/cgroup_v1")
+		defer os.Unsetenv("HOST_SYS")
+
+		So(getCgroupPressureMetricTypes(), ShouldBeEmpty)
+	})
+}
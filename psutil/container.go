@@ -0,0 +1,120 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// containerScopeRe matches the cgroup of a container, either named after
+	// the bare container id (cgroupfs driver) or a systemd scope prefixed with
+	// the runtime (systemd driver), e.g. docker-<id>.scope
+	containerScopeRe = regexp.MustCompile(`^(?:(docker|cri-containerd|crio|libpod)-)?([0-9a-f]{64})(?:\.scope)?$`)
+	// podRe matches the cgroup of a Kubernetes pod, e.g. pod<uid> (cgroupfs
+	// driver) or kubepods-burstable-pod<uid>.slice (systemd driver, with
+	// dashes of the uid replaced by underscores)
+	podRe = regexp.MustCompile(`^(?:kubepods(?:-(burstable|besteffort))?-)?pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})(?:\.slice)?$`)
+	// systemdUnitRe matches cgroups named after the systemd unit they hold
+	systemdUnitRe = regexp.MustCompile(`^[^/]+\.(service|scope|slice)$`)
+)
+
+// containerRuntimes maps the prefixes of container scopes to the runtime
+// which creates them
+var containerRuntimes = map[string]string{
+	"docker":         "docker",
+	"cri-containerd": "containerd",
+	"crio":           "cri-o",
+	"libpod":         "podman",
+}
+
+// containerIdentity holds what the path of a cgroup tells about the
+// workload it runs: the container, the Kubernetes pod and QoS class it
+// belongs to and the innermost systemd unit. It is derived from the naming
+// conventions of the runtimes, without contacting them.
+type containerIdentity struct {
+	containerID string
+	runtime     string
+	podUID      string
+	qosClass    string
+	systemdUnit string
+}
+
+// getContainerIdentity parses a cgroup path such as
+// /kubepods/burstable/pod<uid>/<id> or
+// /system.slice/docker-<id>.scope
+func getContainerIdentity(path string) *containerIdentity {
+	id := &containerIdentity{}
+	kubepods := false
+	parent := ""
+	for _, element := range strings.Split(path, "/") {
+		switch element {
+		case "kubepods", "kubepods.slice":
+			kubepods = true
+		case "burstable", "besteffort":
+			if kubepods && id.podUID == "" {
+				id.qosClass = element
+			}
+		case "kubepods-burstable.slice", "kubepods-besteffort.slice":
+			id.qosClass = strings.TrimSuffix(strings.TrimPrefix(element, "kubepods-"), ".slice")
+		}
+		if m := podRe.FindStringSubmatch(element); m != nil {
+			id.podUID = strings.Replace(m[2], "_", "-", -1)
+			if m[1] != "" {
+				id.qosClass = m[1]
+			}
+		}
+		if m := containerScopeRe.FindStringSubmatch(element); m != nil {
+			id.containerID = m[2]
+			id.runtime = containerRuntimes[m[1]]
+			if id.runtime == "" && parent == "docker" {
+				// /docker/<id> with the cgroupfs driver of docker
+				id.runtime = "docker"
+			}
+		}
+		if systemdUnitRe.MatchString(element) {
+			id.systemdUnit = element
+		}
+		parent = element
+	}
+	if id.podUID != "" && id.qosClass == "" {
+		// guaranteed pods live directly under kubepods
+		id.qosClass = "guaranteed"
+	}
+	return id
+}
+
+// tags returns the identity in the form attached to metrics, leaving out
+// what could not be derived from the path
+func (id *containerIdentity) tags() map[string]string {
+	tags := map[string]string{}
+	for k, v := range map[string]string{
+		"container_id":      id.containerID,
+		"container_runtime": id.runtime,
+		"pod_uid":           id.podUID,
+		"qos_class":         id.qosClass,
+		"systemd_unit":      id.systemdUnit,
+	} {
+		if v != "" {
+			tags[k] = v
+		}
+	}
+	return tags
+}
@@ -0,0 +1,81 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetContainerIdentity(t *testing.T) {
+	id := "4b825dc642cb6eb9a060e54bf8d69288fbee4904c3a1a3e3f5d4b7e2c1a0f9e8"
+	Convey("Derive the container identity from cgroup paths", t, func() {
+		tests := []struct {
+			path string
+			tags map[string]string
+		}{
+			{"/docker/" + id, map[string]string{
+				"container_id":      id,
+				"container_runtime": "docker",
+			}},
+			{"/system.slice/docker-" + id + ".scope", map[string]string{
+				"container_id":      id,
+				"container_runtime": "docker",
+				"systemd_unit":      "docker-" + id + ".scope",
+			}},
+			{"/kubepods/burstable/pod0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0/" + id, map[string]string{
+				"container_id": id,
+				"pod_uid":      "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0",
+				"qos_class":    "burstable",
+			}},
+			{"/kubepods/pod0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0", map[string]string{
+				"pod_uid":   "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0",
+				"qos_class": "guaranteed",
+			}},
+			{"/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod0f1e2d3c_4b5a_6978_8796_a5b4c3d2e1f0.slice/cri-containerd-" + id + ".scope", map[string]string{
+				"container_id":      id,
+				"container_runtime": "containerd",
+				"pod_uid":           "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0",
+				"qos_class":         "besteffort",
+				"systemd_unit":      "cri-containerd-" + id + ".scope",
+			}},
+			{"/kubepods.slice/kubepods-pod0f1e2d3c_4b5a_6978_8796_a5b4c3d2e1f0.slice/crio-" + id + ".scope", map[string]string{
+				"container_id":      id,
+				"container_runtime": "cri-o",
+				"pod_uid":           "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0",
+				"qos_class":         "guaranteed",
+				"systemd_unit":      "crio-" + id + ".scope",
+			}},
+			{"/system.slice/sshd.service", map[string]string{
+				"systemd_unit": "sshd.service",
+			}},
+			{"/user.slice/user-1000.slice/session-2.scope", map[string]string{
+				"systemd_unit": "session-2.scope",
+			}},
+			{"/process_api", map[string]string{}},
+		}
+		for _, test := range tests {
+			So(getContainerIdentity(test.path).tags(), ShouldResemble, test.tags)
+		}
+	})
+}
@@ -29,66 +29,63 @@ import (
 
 var cpuLabels = map[string]label{
 	"user": label{
-		description: "",
-		unit:        "",
+		description: "time spent in user mode",
+		unit:        unitSeconds,
 	},
 	"system": label{
-		description: "",
-		unit:        "",
+		description: "time spent in system mode",
+		unit:        unitSeconds,
 	},
 	"idle": label{
-		description: "",
-		unit:        "",
+		description: "time spent in the idle task",
+		unit:        unitSeconds,
 	},
 	"nice": label{
-		description: "",
-		unit:        "",
+		description: "time spent in user mode with low priority (nice)",
+		unit:        unitSeconds,
 	},
 	"iowait": label{
-		description: "",
-		unit:        "",
+		description: "time waiting for I/O to complete",
+		unit:        unitSeconds,
 	},
 	"irq": label{
-		description: "",
-		unit:        "",
+		description: "time spent servicing interrupts",
+		unit:        unitSeconds,
 	},
 	"softirq": label{
-		description: "",
-		unit:        "",
+		description: "time spent servicing softirqs",
+		unit:        unitSeconds,
 	},
 	"steal": label{
-		description: "",
-		unit:        "",
+		description: "stolen time, which is the time spent in other operating systems when running in a virtualized environment",
+		unit:        unitSeconds,
 	},
 	"guest": label{
-		description: "",
-		unit:        "",
+		description: "time spent running a virtual cpu for guest operating systems",
+		unit:        unitSeconds,
 	},
 	"guest_nice": label{
-		description: "",
-		unit:        "",
+		description: "time spent running a niced guest (virtual cpu for guest operating systems)",
+		unit:        unitSeconds,
 	},
 	"stolen": label{
-		description: "",
-		unit:        "",
+		description: "stolen time, which is the time spent in other operating systems when running in a virtualized environment",
+		unit:        unitSeconds,
 	},
 }
 
-func cpuTimes(nss []plugin.Namespace) ([]plugin.Metric, error) {
-	// gather metrics per each cpu
+func cpuTimes(nss []plugin.Namespace, stat *procStat, units *unitConversion) ([]plugin.Metric, error) {
 	defer timeSpent(time.Now(), "cpuTimes")
-	timesCPUs, err := cpu.Times(true)
-	if err != nil {
-		return nil, err
+	if len(nss) == 0 {
+		return nil, nil
 	}
-
-	// gather accumulated metrics for all cpus
-	timesAll, err := cpu.Times(false)
+	timesCPUs, timesAll, err := getCPUTimes(stat)
 	if err != nil {
 		return nil, err
 	}
 
 	results := []plugin.Metric{}
+	requests := cpuRequestSet(nss)
 
 	for _, ns := range nss {
 		// set requested metric name from last namespace element
@@ -109,11 +106,19 @@ func cpuTimes(nss []plugin.Namespace) ([]plugin.Metric, error) {
 				metric := plugin.Metric{
 					Namespace: dyn,
 					Data:      val,
+					Tags:      cpuTags(timesCPU.CPU, nil),
 					Timestamp: time.Now(),
 					Unit:      cpuLabels[metricName].unit,
 				}
 				results = append(results, metric)
 			}
+		} else if cpuGroupRe.MatchString(ns[3].Value) {
+			// aggregate of cpus sharing a socket or NUMA node
+			metric, err := cpuGroupMetric(ns, timesCPUs, requests)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, metric)
 		} else {
 			timeStats := append(timesAll, timesCPUs...)
 			// find stats for interface name or all cpus
@@ -129,6 +134,7 @@ func cpuTimes(nss []plugin.Namespace) ([]plugin.Metric, error) {
 			metric := plugin.Metric{
 				Namespace: ns,
 				Data:      val,
+				Tags:      cpuTags(timeStat.CPU, nil),
 				Timestamp: time.Now(),
 				Unit:      cpuLabels[metricName].unit,
 			}
@@ -136,7 +142,28 @@ func cpuTimes(nss []plugin.Namespace) ([]plugin.Metric, error) {
 		}
 	}
 
-	return results, nil
+	return units.convert(results), nil
+}
+
+// getCPUTimes returns times per each cpu and accumulated for all cpus, from
+// /proc/stat when it was already read for this collection, otherwise with
+// gopsutil
+func getCPUTimes(stat *procStat) ([]cpu.TimesStat, []cpu.TimesStat, error) {
+	if stat != nil {
+		return stat.cpus, []cpu.TimesStat{stat.cpuTotal}, nil
+	}
+	// gather metrics per each cpu
+	timesCPUs, err := cpu.Times(true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// gather accumulated metrics for all cpus
+	timesAll, err := cpu.Times(false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return timesCPUs, timesAll, nil
 }
 
 func findCPUTimeStat(timeStats []cpu.TimesStat, name string) *cpu.TimesStat {
@@ -191,7 +218,7 @@ func getCPUTimesMetricTypes() ([]plugin.Metric, error) {
 			})
 			mts = append(mts, plugin.Metric{
 				Namespace:   plugin.NewNamespace("intel", "psutil", "cpu", "cpu-total").AddStaticElement(k),
-				Description: label.description,
+				Description: label.description + ", accumulated over all cpus",
 				Unit:        label.unit,
 			})
 		}
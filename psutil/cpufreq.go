@@ -0,0 +1,303 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+var cpuFreqLabels = map[string]label{
+	"freq_current_mhz": label{
+		description: "current frequency of the cpu as seen by the kernel",
+		unit:        unitMegahertz,
+	},
+	"freq_min_mhz": label{
+		description: "minimum frequency the cpu can run at",
+		unit:        unitMegahertz,
+	},
+	"freq_max_mhz": label{
+		description: "maximum frequency the cpu can run at",
+		unit:        unitMegahertz,
+	},
+}
+
+// cpuFreq holds the frequency of a single cpu; min and max are not known
+// when the frequency comes from /proc/cpuinfo
+type cpuFreq struct {
+	current   float64
+	min       float64
+	max       float64
+	hasLimits bool
+	governor  string
+}
+
+func cpuFrequencies(nss []plugin.Namespace) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "cpuFrequencies")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	freqs, err := getCPUFreqs()
+	if err != nil {
+		return nil, err
+	}
+	if len(freqs) == 0 {
+		// the metrics are not advertised without a frequency source, but
+		// may still be requested by a task created for another host
+		return nil, nil
+	}
+
+	results := []plugin.Metric{}
+
+	for _, ns := range nss {
+		// set requested metric name from last namespace element
+		metricName := ns.Element(len(ns) - 1).Value
+		cpus := []string{ns[3].Value}
+		// check if requested metric is dynamic (requesting metrics for all cpu ids)
+		if ns[3].Value == "*" {
+			cpus = sortedCPUNames(freqs)
+		} else if _, ok := freqs[ns[3].Value]; !ok {
+			return nil, fmt.Errorf("Requested cpu id %s not found", ns[3].Value)
+		}
+		for _, name := range cpus {
+			if ns[3].Value == "*" && name == "cpu-total" {
+				continue
+			}
+			freq := freqs[name]
+			val, ok, err := getCPUFreqValue(freq, metricName)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			// prepare namespace copy to update value
+			// this will allow to keep namespace as dynamic (name != "")
+			dyn := make([]plugin.NamespaceElement, len(ns))
+			copy(dyn, ns)
+			dyn[3].Value = name
+			var tags map[string]string
+			if freq.governor != "" {
+				tags = map[string]string{"governor": freq.governor}
+			}
+			results = append(results, plugin.Metric{
+				Namespace: dyn,
+				Data:      val,
+				Tags:      cpuTags(name, tags),
+				Timestamp: time.Now(),
+				Unit:      cpuFreqLabels[metricName].unit,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+func getCPUFreqValue(freq *cpuFreq, name string) (float64, bool, error) {
+	switch name {
+	case "freq_current_mhz":
+		return freq.current, true, nil
+	case "freq_min_mhz":
+		return freq.min, freq.hasLimits, nil
+	case "freq_max_mhz":
+		return freq.max, freq.hasLimits, nil
+	default:
+		return 0, false, fmt.Errorf("Requested cpu frequency statistic %s is not available", name)
+	}
+}
+
+// cpuFreqSource returns where cpu frequencies are read from, cpufreq or
+// cpuinfo, or an empty string when neither reports them (e.g. many ARM boards)
+func cpuFreqSource() string {
+	if dirs, _ := filepath.Glob(hostSys("devices", "system", "cpu", "cpu[0-9]*", "cpufreq")); len(dirs) > 0 {
+		return "cpufreq"
+	}
+	f, err := os.Open(hostProc("cpuinfo"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	if mhz, err := parseCPUInfoMHz(f); err == nil && len(mhz) > 0 {
+		return "cpuinfo"
+	}
+	return ""
+}
+
+// getCPUFreqs reads frequencies of all cpus from cpufreq in sysfs, or from
+// /proc/cpuinfo on hosts without a cpufreq driver (e.g. most VMs), and adds
+// their average as cpu-total; no frequencies are returned when neither
+// reports them
+func getCPUFreqs() (map[string]*cpuFreq, error) {
+	freqs := map[string]*cpuFreq{}
+	dirs, err := filepath.Glob(hostSys("devices", "system", "cpu", "cpu[0-9]*", "cpufreq"))
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		current, ok := readCPUFreqMHz(dir, "scaling_cur_freq")
+		if !ok {
+			if current, ok = readCPUFreqMHz(dir, "cpuinfo_cur_freq"); !ok {
+				continue
+			}
+		}
+		freq := &cpuFreq{
+			current:  current,
+			governor: readSysfsString(filepath.Join(dir, "scaling_governor")),
+		}
+		min, minOk := readCPUFreqMHz(dir, "cpuinfo_min_freq")
+		max, maxOk := readCPUFreqMHz(dir, "cpuinfo_max_freq")
+		if minOk && maxOk {
+			freq.min, freq.max, freq.hasLimits = min, max, true
+		}
+		freqs[filepath.Base(filepath.Dir(dir))] = freq
+	}
+
+	if len(freqs) == 0 {
+		f, err := os.Open(hostProc("cpuinfo"))
+		if os.IsNotExist(err) {
+			return freqs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		mhz, err := parseCPUInfoMHz(f)
+		if err != nil {
+			return nil, err
+		}
+		for name, current := range mhz {
+			freqs[name] = &cpuFreq{current: current}
+		}
+	}
+	if len(freqs) == 0 {
+		return freqs, nil
+	}
+
+	freqs["cpu-total"] = averageCPUFreq(freqs)
+	return freqs, nil
+}
+
+// averageCPUFreq averages frequencies over all cpus; the governor is kept
+// only if all cpus share it
+func averageCPUFreq(freqs map[string]*cpuFreq) *cpuFreq {
+	total := &cpuFreq{hasLimits: true}
+	governors := map[string]bool{}
+	for _, freq := range freqs {
+		total.current += freq.current
+		total.min += freq.min
+		total.max += freq.max
+		total.hasLimits = total.hasLimits && freq.hasLimits
+		governors[freq.governor] = true
+		total.governor = freq.governor
+	}
+	n := float64(len(freqs))
+	total.current, total.min, total.max = total.current/n, total.min/n, total.max/n
+	if len(governors) > 1 {
+		total.governor = ""
+	}
+	return total
+}
+
+// readCPUFreqMHz reads a cpufreq file, which holds a frequency in kHz
+func readCPUFreqMHz(dir, file string) (float64, bool) {
+	khz, err := strconv.ParseFloat(readSysfsString(filepath.Join(dir, file)), 64)
+	if err != nil {
+		return 0, false
+	}
+	return khz / 1000, true
+}
+
+// parseCPUInfoMHz returns the "cpu MHz" of each processor in /proc/cpuinfo
+func parseCPUInfoMHz(r io.Reader) (map[string]float64, error) {
+	mhz := map[string]float64{}
+	processor := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+		switch key {
+		case "processor":
+			processor = "cpu" + value
+		case "cpu MHz":
+			freq, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid cpu MHz of %s: %s", processor, value)
+			}
+			mhz[processor] = freq
+		}
+	}
+	return mhz, scanner.Err()
+}
+
+// sortedCPUNames returns cpu names in numeric order (cpu2 before cpu10)
+func sortedCPUNames(freqs map[string]*cpuFreq) []string {
+	names := make([]string, 0, len(freqs))
+	for name := range freqs {
+		names = append(names, name)
+	}
+	sort.Sort(byCPUNumber(names))
+	return names
+}
+
+// byCPUNumber sorts cpu names by length first, so that they are in numeric order
+type byCPUNumber []string
+
+func (c byCPUNumber) Len() int      { return len(c) }
+func (c byCPUNumber) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byCPUNumber) Less(i, j int) bool {
+	if len(c[i]) != len(c[j]) {
+		return len(c[i]) < len(c[j])
+	}
+	return c[i] < c[j]
+}
+
+func getCPUFreqMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getCPUFreqMetricTypes")
+	mts := []plugin.Metric{}
+	// only advertised when cpufreq or /proc/cpuinfo reports frequencies
+	if runtime.GOOS != "linux" || cpuFreqSource() == "" {
+		return mts
+	}
+	for k, label := range cpuFreqLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace:   plugin.NewNamespace("intel", "psutil", "cpu").AddDynamicElement("cpu_id", "physical cpu id").AddStaticElement(k),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+		mts = append(mts, plugin.Metric{
+			Namespace:   plugin.NewNamespace("intel", "psutil", "cpu", "cpu-total").AddStaticElement(k),
+			Description: label.description + " averaged over all cpus",
+			Unit:        label.unit,
+		})
+	}
+	return mts
+}
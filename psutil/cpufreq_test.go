@@ -0,0 +1,127 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package psutil
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestParseCPUInfoMHz(t *testing.T) {
+	Convey("Parse cpu MHz from /proc/cpuinfo", t, func() {
+		Convey("of x86 processors", func() {
+			f, err := os.Open("testdata/cpuinfo")
+			So(err, ShouldBeNil)
+			defer f.Close()
+			mhz, err := parseCPUInfoMHz(f)
+			So(err, ShouldBeNil)
+			So(mhz, ShouldResemble, map[string]float64{"cpu0": 2294.608, "cpu1": 1000, "cpu2": 2300})
+		})
+		Convey("of ARM processors, which do not report it", func() {
+			f, err := os.Open("testdata/cpuinfo_arm")
+			So(err, ShouldBeNil)
+			defer f.Close()
+			mhz, err := parseCPUInfoMHz(f)
+			So(err, ShouldBeNil)
+			So(mhz, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestGetCPUFreqs(t *testing.T) {
+	Convey("Read cpu frequencies", t, func() {
+		defer os.Unsetenv("HOST_SYS")
+		defer os.Unsetenv("HOST_PROC")
+
+		Convey("from cpufreq", func() {
+			os.Setenv("HOST_SYS", "testdata/sys")
+			So(cpuFreqSource(), ShouldEqual, "cpufreq")
+			freqs, err := getCPUFreqs()
+			So(err, ShouldBeNil)
+			So(sortedCPUNames(freqs), ShouldResemble, []string{"cpu0", "cpu1", "cpu-total"})
+			So(*freqs["cpu0"], ShouldResemble, cpuFreq{current: 2400, min: 800, max: 3600, hasLimits: true, governor: "performance"})
+			// falls back to cpuinfo_cur_freq without scaling_cur_freq
+			So(freqs["cpu1"].current, ShouldEqual, float64(1200))
+			// governors differ, so the average has none
+			So(*freqs["cpu-total"], ShouldResemble, cpuFreq{current: 1800, min: 800, max: 3600, hasLimits: true})
+		})
+
+		Convey("from /proc/cpuinfo without cpufreq", func() {
+			os.Setenv("HOST_SYS", "testdata/dev")
+			os.Setenv("HOST_PROC", "testdata")
+			So(cpuFreqSource(), ShouldEqual, "cpuinfo")
+			freqs, err := getCPUFreqs()
+			So(err, ShouldBeNil)
+			So(freqs["cpu1"].current, ShouldEqual, float64(1000))
+			So(freqs["cpu1"].hasLimits, ShouldBeFalse)
+			So(freqs["cpu-total"].hasLimits, ShouldBeFalse)
+		})
+
+		Convey("without any source", func() {
+			os.Setenv("HOST_SYS", "testdata/dev")
+			os.Setenv("HOST_PROC", "testdata/dev")
+			So(cpuFreqSource(), ShouldEqual, "")
+			freqs, err := getCPUFreqs()
+			So(err, ShouldBeNil)
+			So(freqs, ShouldBeEmpty)
+			metrics, err := cpuFrequencies([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "cpu", "cpu-total", "freq_current_mhz"),
+			})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestAverageCPUFreq(t *testing.T) {
+	Convey("Average cpu frequencies", t, func() {
+		Convey("keeping a governor shared by all cpus", func() {
+			total := averageCPUFreq(map[string]*cpuFreq{
+				"cpu0": &cpuFreq{current: 1000, min: 800, max: 3000, hasLimits: true, governor: "schedutil"},
+				"cpu1": &cpuFreq{current: 3000, min: 800, max: 3000, hasLimits: true, governor: "schedutil"},
+			})
+			So(*total, ShouldResemble, cpuFreq{current: 2000, min: 800, max: 3000, hasLimits: true, governor: "schedutil"})
+		})
+		Convey("without limits unless all cpus have them", func() {
+			total := averageCPUFreq(map[string]*cpuFreq{
+				"cpu0": &cpuFreq{current: 1000, min: 800, max: 3000, hasLimits: true},
+				"cpu1": &cpuFreq{current: 2000},
+			})
+			So(total.current, ShouldEqual, float64(1500))
+			So(total.hasLimits, ShouldBeFalse)
+		})
+	})
+}
+
+func TestByCPUNumber(t *testing.T) {
+	Convey("Sort cpu names in numeric order", t, func() {
+		names := []string{"cpu10", "cpu2", "cpu-total", "cpu1", "cpu0"}
+		sort.Sort(byCPUNumber(names))
+		So(names, ShouldResemble, []string{"cpu0", "cpu1", "cpu2", "cpu10", "cpu-total"})
+	})
+}
@@ -0,0 +1,210 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/shirou/gopsutil/cpu"
+)
+
+// cpuGroupRe matches the aggregates of cpus sharing a socket or a NUMA node,
+// e.g. socket0 or node1
+var cpuGroupRe = regexp.MustCompile(`^(socket|node)(\d+)$`)
+
+var cpuGroupLabels = map[string]label{
+	"utilization_percent": label{
+		description: "percentage of time the cpus were not idle nor waiting for I/O since the previous collection",
+		unit:        unitPercent,
+	},
+}
+
+// cpuGroupSamples keeps the last busy and total time of each group to
+// compute utilization between collections; samples are kept per set of
+// requested metrics so that tasks collecting at different intervals do not
+// shorten each other's interval
+var cpuGroupSamples = struct {
+	sync.Mutex
+	last map[cpuGroupSampleKey][2]float64
+}{last: map[cpuGroupSampleKey][2]float64{}}
+
+type cpuGroupSampleKey struct {
+	requests string
+	group    string
+}
+
+// cpuRequestSet identifies the cpu metrics requested by a task; the plugin
+// is not told which task collects, so tasks requesting the same metrics
+// share their samples
+func cpuRequestSet(nss []plugin.Namespace) string {
+	names := make([]string, len(nss))
+	for i, ns := range nss {
+		names[i] = ns.String()
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
+
+// cpuGroupMembers returns the cpus belonging to a socket<N> or node<N> group
+func cpuGroupMembers(group string) []string {
+	m := cpuGroupRe.FindStringSubmatch(group)
+	if m == nil {
+		return nil
+	}
+	tag := "socket"
+	if m[1] == "node" {
+		tag = "numa_node"
+	}
+	members := []string{}
+	for name, tags := range getCPUTopologies() {
+		if tags[tag] == m[2] {
+			members = append(members, name)
+		}
+	}
+	sort.Sort(byCPUNumber(members))
+	return members
+}
+
+// cpuGroups lists the socket<N> and node<N> groups present on the host
+func cpuGroups() []string {
+	groups := map[string]bool{}
+	for _, tags := range getCPUTopologies() {
+		if socket, ok := tags["socket"]; ok {
+			groups["socket"+socket] = true
+		}
+		if node, ok := tags["numa_node"]; ok {
+			groups["node"+node] = true
+		}
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// aggregateCPUTimes sums times of the group members into a single stat
+// named after the group
+func aggregateCPUTimes(timesCPUs []cpu.TimesStat, group string, members []string) *cpu.TimesStat {
+	total := &cpu.TimesStat{CPU: group}
+	found := false
+	for _, member := range members {
+		stat := findCPUTimeStat(timesCPUs, member)
+		if stat == nil {
+			continue
+		}
+		found = true
+		total.User += stat.User
+		total.System += stat.System
+		total.Idle += stat.Idle
+		total.Nice += stat.Nice
+		total.Iowait += stat.Iowait
+		total.Irq += stat.Irq
+		total.Softirq += stat.Softirq
+		total.Steal += stat.Steal
+		total.Guest += stat.Guest
+		total.GuestNice += stat.GuestNice
+		total.Stolen += stat.Stolen
+	}
+	if !found {
+		return nil
+	}
+	return total
+}
+
+// cpuGroupMetric returns the requested statistic of a socket or NUMA node
+// aggregate
+func cpuGroupMetric(ns plugin.Namespace, timesCPUs []cpu.TimesStat, requests string) (plugin.Metric, error) {
+	group := ns[3].Value
+	metricName := ns.Element(len(ns) - 1).Value
+	members := cpuGroupMembers(group)
+	stat := aggregateCPUTimes(timesCPUs, group, members)
+	if stat == nil {
+		return plugin.Metric{}, fmt.Errorf("Requested cpu group %s not found", group)
+	}
+	var val float64
+	var err error
+	unit := cpuLabels[metricName].unit
+	if metricName == "utilization_percent" {
+		val = cpuGroupUtilization(requests, group, stat)
+		unit = cpuGroupLabels[metricName].unit
+	} else if val, err = getCPUTimeValue(stat, metricName); err != nil {
+		return plugin.Metric{}, err
+	}
+	return plugin.Metric{
+		Namespace: ns,
+		Data:      val,
+		Tags:      map[string]string{"cpus": strings.Join(members, ",")},
+		Timestamp: time.Now(),
+		Unit:      unit,
+	}, nil
+}
+
+// cpuGroupUtilization computes the busy percentage of a group since the
+// previous call for the same requests, or since boot on the first one; guest
+// time is already accounted in user and nice time
+func cpuGroupUtilization(requests, group string, stat *cpu.TimesStat) float64 {
+	busy := stat.User + stat.System + stat.Nice + stat.Irq + stat.Softirq + stat.Steal
+	total := busy + stat.Idle + stat.Iowait
+
+	key := cpuGroupSampleKey{requests: requests, group: group}
+	cpuGroupSamples.Lock()
+	last := cpuGroupSamples.last[key]
+	cpuGroupSamples.last[key] = [2]float64{busy, total}
+	cpuGroupSamples.Unlock()
+
+	if total-last[1] <= 0 {
+		return 0
+	}
+	return 100 * (busy - last[0]) / (total - last[1])
+}
+
+func getCPUGroupMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getCPUGroupMetricTypes")
+	mts := []plugin.Metric{}
+	for _, group := range cpuGroups() {
+		m := cpuGroupRe.FindStringSubmatch(group)
+		kind, id := m[1], m[2]
+		if kind == "node" {
+			kind = "NUMA node"
+		}
+		for k, label := range cpuLabels {
+			mts = append(mts, plugin.Metric{
+				Namespace:   plugin.NewNamespace("intel", "psutil", "cpu", group).AddStaticElement(k),
+				Description: fmt.Sprintf("%s, accumulated over all cpus of %s %s", label.description, kind, id),
+				Unit:        label.unit,
+			})
+		}
+		for k, label := range cpuGroupLabels {
+			mts = append(mts, plugin.Metric{
+				Namespace:   plugin.NewNamespace("intel", "psutil", "cpu", group).AddStaticElement(k),
+				Description: fmt.Sprintf("%s, for all cpus of %s %s", label.description, kind, id),
+				Unit:        label.unit,
+			})
+		}
+	}
+	return mts
+}
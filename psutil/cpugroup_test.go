@@ -0,0 +1,123 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/shirou/gopsutil/cpu"
+)
+
+func TestCPUGroups(t *testing.T) {
+	Convey("Group cpus by socket and NUMA node", t, func() {
+		os.Setenv("HOST_SYS", "testdata/sys")
+		os.Setenv("HOST_PROC", "testdata")
+		cpuTopologyCache.Lock()
+		cpuTopologyCache.tags = nil
+		cpuTopologyCache.Unlock()
+		defer func() {
+			os.Unsetenv("HOST_SYS")
+			os.Unsetenv("HOST_PROC")
+			cpuTopologyCache.Lock()
+			cpuTopologyCache.tags = nil
+			cpuTopologyCache.Unlock()
+		}()
+
+		So(cpuGroups(), ShouldResemble, []string{"node0", "node1", "socket0", "socket1"})
+		So(cpuGroupMembers("socket0"), ShouldResemble, []string{"cpu0", "cpu1"})
+		So(cpuGroupMembers("node1"), ShouldResemble, []string{"cpu2"})
+		So(cpuGroupMembers("socket7"), ShouldBeEmpty)
+		So(cpuGroupMembers("cpu0"), ShouldBeNil)
+
+		Convey("from a copy of the cached topology", func() {
+			topologies := getCPUTopologies()
+			topologies["cpu0"]["socket"] = "9"
+			delete(topologies, "cpu1")
+			So(getCPUTopology("cpu0")["socket"], ShouldEqual, "0")
+			So(getCPUTopologies(), ShouldContainKey, "cpu1")
+		})
+	})
+}
+
+func TestAggregateCPUTimes(t *testing.T) {
+	Convey("Aggregate cpu times of a group", t, func() {
+		timesCPUs := []cpu.TimesStat{
+			{CPU: "cpu0", User: 10, System: 5, Idle: 100, Iowait: 1, Steal: 0.5},
+			{CPU: "cpu1", User: 20, System: 10, Idle: 50, Nice: 2, Irq: 1, Softirq: 3},
+			{CPU: "cpu2", User: 1000, Idle: 1000},
+		}
+
+		Convey("summing the times of its members", func() {
+			total := aggregateCPUTimes(timesCPUs, "socket0", []string{"cpu0", "cpu1"})
+			So(*total, ShouldResemble, cpu.TimesStat{
+				CPU: "socket0", User: 30, System: 15, Idle: 150, Nice: 2,
+				Iowait: 1, Irq: 1, Softirq: 3, Steal: 0.5,
+			})
+		})
+		Convey("skipping members without times, e.g. offline cpus", func() {
+			total := aggregateCPUTimes(timesCPUs, "node1", []string{"cpu2", "cpu3"})
+			So(total.User, ShouldEqual, float64(1000))
+			So(total.Idle, ShouldEqual, float64(1000))
+		})
+		Convey("of a group without any times", func() {
+			So(aggregateCPUTimes(timesCPUs, "socket1", []string{"cpu3"}), ShouldBeNil)
+			So(aggregateCPUTimes(timesCPUs, "socket1", nil), ShouldBeNil)
+		})
+	})
+}
+
+func TestCPUGroupUtilization(t *testing.T) {
+	Convey("Compute utilization of a group between collections", t, func() {
+		requests := cpuRequestSet([]plugin.Namespace{
+			plugin.NewNamespace("intel", "psutil", "cpu", "socket0", "utilization_percent"),
+		})
+		other := cpuRequestSet([]plugin.Namespace{
+			plugin.NewNamespace("intel", "psutil", "cpu", "socket0", "utilization_percent"),
+			plugin.NewNamespace("intel", "psutil", "cpu", "cpu-total", "user"),
+		})
+		So(requests, ShouldNotEqual, other)
+		cpuGroupSamples.Lock()
+		cpuGroupSamples.last = map[cpuGroupSampleKey][2]float64{}
+		cpuGroupSamples.Unlock()
+
+		// busy 60 of total 200 since boot
+		first := &cpu.TimesStat{User: 40, System: 10, Nice: 5, Softirq: 5, Idle: 130, Iowait: 10}
+		So(cpuGroupUtilization(requests, "socket0", first), ShouldEqual, float64(30))
+
+		// busy 90 of the 100 elapsed since then
+		second := &cpu.TimesStat{User: 120, System: 20, Nice: 5, Softirq: 5, Idle: 135, Iowait: 15}
+		So(cpuGroupUtilization(requests, "socket0", second), ShouldEqual, float64(90))
+
+		Convey("separately for each set of requests", func() {
+			So(cpuGroupUtilization(other, "socket0", second), ShouldEqual, float64(150*100)/300)
+			So(cpuGroupUtilization(requests, "socket1", first), ShouldEqual, float64(30))
+		})
+		Convey("without time elapsed", func() {
+			So(cpuGroupUtilization(requests, "socket0", second), ShouldEqual, float64(0))
+		})
+	})
+}
@@ -0,0 +1,237 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+var cpuIdleLabels = map[string]label{
+	"time_us": label{
+		description: "time spent in the idle state since boot",
+		unit:        unitMicroseconds,
+	},
+	"usage": label{
+		description: "number of times the idle state was entered since boot",
+		unit:        unitCount,
+	},
+	"latency_us": label{
+		description: "exit latency of the idle state",
+		unit:        unitMicroseconds,
+	},
+	"disabled": label{
+		description: "1 if the idle state is disabled, 0 otherwise",
+		unit:        unitBoolean,
+	},
+}
+
+// cpuIdleState holds the statistics of an idle state of a cpu
+type cpuIdleState struct {
+	name      string
+	timeUs    uint64
+	usage     uint64
+	latencyUs uint64
+	disabled  uint64
+}
+
+// cpuIdleDriver returns the cpuidle driver in use, or an empty string when
+// there is none
+func cpuIdleDriver() string {
+	driver := readSysfsString(hostSys("devices", "system", "cpu", "cpuidle", "current_driver"))
+	if driver == "none" {
+		return ""
+	}
+	return driver
+}
+
+func cpuIdleStats(nss []plugin.Namespace) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "cpuIdleStats")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	driver := cpuIdleDriver()
+	if driver == "" {
+		// the metrics are not advertised without a cpuidle driver, but may
+		// still be requested by a task created for another host
+		return nil, nil
+	}
+	states, err := getCPUIdleStates()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, ns := range nss {
+		metricName := ns.Element(len(ns) - 1).Value
+		for _, name := range sortedIdleCPUNames(states) {
+			if ns[3].Value == "*" && name == "cpu-total" {
+				continue
+			}
+			if ns[3].Value != "*" && ns[3].Value != name {
+				continue
+			}
+			for _, state := range states[name] {
+				if ns[4].Value != "*" && ns[4].Value != state.name {
+					continue
+				}
+				val, err := getCPUIdleValue(&state, metricName)
+				if err != nil {
+					return nil, err
+				}
+				dyn := make([]plugin.NamespaceElement, len(ns))
+				copy(dyn, ns)
+				dyn[3].Value = name
+				dyn[4].Value = state.name
+				results = append(results, plugin.Metric{
+					Namespace: dyn,
+					Data:      val,
+					Tags:      cpuTags(name, map[string]string{"driver": driver}),
+					Timestamp: t,
+					Unit:      cpuIdleUnit(name, metricName),
+				})
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// getCPUIdleStates reads idle states of each cpu from
+// /sys/devices/system/cpu/cpu*/cpuidle and adds their aggregate over all
+// cpus as cpu-total
+func getCPUIdleStates() (map[string][]cpuIdleState, error) {
+	dirs, err := filepath.Glob(hostSys("devices", "system", "cpu", "cpu[0-9]*", "cpuidle"))
+	if err != nil {
+		return nil, err
+	}
+	states := map[string][]cpuIdleState{}
+	total := []cpuIdleState{}
+	// position of each state in total; hybrid processors have different
+	// states on their performance and efficiency cores
+	totalIdx := map[string]int{}
+	for _, dir := range dirs {
+		cpu := filepath.Base(filepath.Dir(dir))
+		stateDirs, _ := filepath.Glob(filepath.Join(dir, "state[0-9]*"))
+		for _, stateDir := range stateDirs {
+			state := cpuIdleState{name: readSysfsString(filepath.Join(stateDir, "name"))}
+			for file, dest := range map[string]*uint64{
+				"time":    &state.timeUs,
+				"usage":   &state.usage,
+				"latency": &state.latencyUs,
+				"disable": &state.disabled,
+			} {
+				*dest, _ = strconv.ParseUint(readSysfsString(filepath.Join(stateDir, file)), 10, 64)
+			}
+			states[cpu] = append(states[cpu], state)
+
+			i, ok := totalIdx[state.name]
+			if !ok {
+				i = len(total)
+				totalIdx[state.name] = i
+				total = append(total, cpuIdleState{name: state.name})
+			}
+			total[i].timeUs += state.timeUs
+			total[i].usage += state.usage
+			if state.latencyUs > total[i].latencyUs {
+				total[i].latencyUs = state.latencyUs
+			}
+			// number of cpus the state is disabled on
+			total[i].disabled += state.disabled
+		}
+	}
+	if len(states) > 0 {
+		states["cpu-total"] = total
+	}
+	return states, nil
+}
+
+func sortedIdleCPUNames(states map[string][]cpuIdleState) []string {
+	names := make([]string, 0, len(states))
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Sort(byCPUNumber(names))
+	return names
+}
+
+// cpuIdleUnit returns the unit of a metric; the disabled flags of all cpus
+// add up to a number of cpus
+func cpuIdleUnit(name, metricName string) string {
+	if name == "cpu-total" && metricName == "disabled" {
+		return unitCount
+	}
+	return cpuIdleLabels[metricName].unit
+}
+
+func getCPUIdleValue(state *cpuIdleState, name string) (uint64, error) {
+	switch name {
+	case "time_us":
+		return state.timeUs, nil
+	case "usage":
+		return state.usage, nil
+	case "latency_us":
+		return state.latencyUs, nil
+	case "disabled":
+		return state.disabled, nil
+	default:
+		return 0, fmt.Errorf("Requested cpuidle statistic %s is not available", name)
+	}
+}
+
+func getCPUIdleMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getCPUIdleMetricTypes")
+	mts := []plugin.Metric{}
+	// only advertised when a cpuidle driver is in use
+	if cpuIdleDriver() == "" {
+		return mts
+	}
+	for k, label := range cpuIdleLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace: plugin.NewNamespace("intel", "psutil", "cpuidle").
+				AddDynamicElement("cpu_id", "physical cpu id").
+				AddDynamicElement("state_name", "idle state name (POLL, C1, C6, ...)").
+				AddStaticElement(k),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+		description := label.description + " summed over all cpus"
+		switch k {
+		case "latency_us":
+			description = "highest exit latency of the idle state over all cpus"
+		case "disabled":
+			description = "number of cpus the idle state is disabled on"
+		}
+		mts = append(mts, plugin.Metric{
+			Namespace: plugin.NewNamespace("intel", "psutil", "cpuidle", "cpu-total").
+				AddDynamicElement("state_name", "idle state name (POLL, C1, C6, ...)").
+				AddStaticElement(k),
+			Description: description,
+			Unit:        cpuIdleUnit("cpu-total", k),
+		})
+	}
+	return mts
+}
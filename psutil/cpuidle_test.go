@@ -0,0 +1,83 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestGetCPUIdleStates(t *testing.T) {
+	Convey("Read cpu idle states", t, func() {
+		os.Setenv("HOST_SYS", "testdata/sys")
+		defer os.Unsetenv("HOST_SYS")
+
+		So(cpuIdleDriver(), ShouldEqual, "intel_idle")
+		states, err := getCPUIdleStates()
+		So(err, ShouldBeNil)
+		So(sortedIdleCPUNames(states), ShouldResemble, []string{"cpu0", "cpu1", "cpu2", "cpu-total"})
+
+		Convey("for each cpu", func() {
+			So(states["cpu1"], ShouldResemble, []cpuIdleState{
+				{name: "POLL", timeUs: 80, usage: 6},
+				{name: "C1", timeUs: 3000, usage: 300, latencyUs: 2},
+				{name: "C6", timeUs: 70000, usage: 500, latencyUs: 133, disabled: 1},
+			})
+		})
+
+		Convey("of an efficiency core with its own states", func() {
+			So(states["cpu2"], ShouldResemble, []cpuIdleState{
+				{name: "POLL", timeUs: 40, usage: 4},
+				{name: "C1E", timeUs: 2000, usage: 100, latencyUs: 3},
+				{name: "C6S", timeUs: 40000, usage: 250, latencyUs: 170},
+			})
+		})
+
+		Convey("aggregated over all cpus by state name", func() {
+			So(states["cpu-total"], ShouldResemble, []cpuIdleState{
+				{name: "POLL", timeUs: 240, usage: 20},
+				{name: "C1", timeUs: 8000, usage: 700, latencyUs: 2},
+				{name: "C6", timeUs: 160000, usage: 1200, latencyUs: 133, disabled: 1},
+				{name: "C1E", timeUs: 2000, usage: 100, latencyUs: 3},
+				{name: "C6S", timeUs: 40000, usage: 250, latencyUs: 170},
+			})
+		})
+	})
+}
+
+func TestCPUIdleStatsWithoutDriver(t *testing.T) {
+	Convey("Collect cpu idle states without a cpuidle driver", t, func() {
+		os.Setenv("HOST_SYS", "testdata/dev")
+		defer os.Unsetenv("HOST_SYS")
+
+		So(cpuIdleDriver(), ShouldEqual, "")
+		metrics, err := cpuIdleStats([]plugin.Namespace{
+			plugin.NewNamespace("intel", "psutil", "cpuidle", "*", "*", "usage"),
+		})
+		So(err, ShouldBeNil)
+		So(metrics, ShouldBeEmpty)
+	})
+}
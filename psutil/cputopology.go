@@ -0,0 +1,176 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// cpuTopologyCache keeps topology tags of each cpu between collections; it is
+// only refreshed when a cpu it does not know about shows up (cpu hotplug)
+var cpuTopologyCache = struct {
+	sync.Mutex
+	tags map[string]map[string]string
+}{}
+
+// cpuTags returns the topology tags of a cpu (e.g. cpu3) merged with the
+// given metric specific tags
+func cpuTags(name string, tags map[string]string) map[string]string {
+	if name == "cpu-total" {
+		return tags
+	}
+	topology := getCPUTopology(name)
+	if len(topology) == 0 {
+		return tags
+	}
+	merged := make(map[string]string, len(topology)+len(tags))
+	for k, v := range topology {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return merged
+}
+
+// getCPUTopologies returns a copy of the topology tags of all known cpus,
+// keyed by cpu name, which is safe to use while other collections update the
+// cache
+func getCPUTopologies() map[string]map[string]string {
+	cpuTopologyCache.Lock()
+	defer cpuTopologyCache.Unlock()
+	if cpuTopologyCache.tags == nil {
+		cpuTopologyCache.tags = readCPUTopology()
+	}
+	topologies := make(map[string]map[string]string, len(cpuTopologyCache.tags))
+	for name, tags := range cpuTopologyCache.tags {
+		copied := make(map[string]string, len(tags))
+		for k, v := range tags {
+			copied[k] = v
+		}
+		topologies[name] = copied
+	}
+	return topologies
+}
+
+func getCPUTopology(name string) map[string]string {
+	cpuTopologyCache.Lock()
+	defer cpuTopologyCache.Unlock()
+	if tags, ok := cpuTopologyCache.tags[name]; ok {
+		return tags
+	}
+	cpuTopologyCache.tags = readCPUTopology()
+	if _, ok := cpuTopologyCache.tags[name]; !ok {
+		// remember unknown cpus so that they do not trigger a reload on each
+		// collection
+		cpuTopologyCache.tags[name] = map[string]string{}
+	}
+	return cpuTopologyCache.tags[name]
+}
+
+// cpuInfoTags maps the /proc/cpuinfo fields attached to cpus to their tag
+var cpuInfoTags = map[string]string{
+	"model name":  "model_name",
+	"vendor_id":   "vendor",
+	"microcode":   "microcode",
+	"physical id": "socket",
+	"core id":     "core",
+}
+
+// readCPUTopology reads socket, core, NUMA node and hyperthread siblings of
+// each cpu from /sys/devices/system/cpu and completes them with the model,
+// vendor and microcode reported in /proc/cpuinfo
+func readCPUTopology() map[string]map[string]string {
+	defer timeSpent(time.Now(), "readCPUTopology")
+	topology := map[string]map[string]string{}
+	dirs, _ := filepath.Glob(hostSys("devices", "system", "cpu", "cpu[0-9]*"))
+	for _, dir := range dirs {
+		tags := map[string]string{}
+		for tag, file := range map[string]string{
+			"socket":   "physical_package_id",
+			"core":     "core_id",
+			"siblings": "thread_siblings_list",
+		} {
+			if value := readSysfsString(filepath.Join(dir, "topology", file)); value != "" {
+				tags[tag] = value
+			}
+		}
+		if nodes, _ := filepath.Glob(filepath.Join(dir, "node[0-9]*")); len(nodes) > 0 {
+			tags["numa_node"] = strings.TrimPrefix(filepath.Base(nodes[0]), "node")
+		}
+		topology[filepath.Base(dir)] = tags
+	}
+
+	f, err := os.Open(hostProc("cpuinfo"))
+	if err != nil {
+		log.Warnf("cpu model information is not available: %v", err)
+		return topology
+	}
+	defer f.Close()
+	infos, err := parseCPUInfoTags(f)
+	if err != nil {
+		log.Warnf("cpu model information is not available: %v", err)
+		return topology
+	}
+	for name, info := range infos {
+		tags, ok := topology[name]
+		if !ok {
+			tags = map[string]string{}
+			topology[name] = tags
+		}
+		for tag, value := range info {
+			if _, ok := tags[tag]; !ok {
+				tags[tag] = value
+			}
+		}
+	}
+	return topology
+}
+
+// parseCPUInfoTags returns the fields of each processor in /proc/cpuinfo
+// listed in cpuInfoTags, keyed by cpu name; offline cpus are not listed
+func parseCPUInfoTags(r io.Reader) (map[string]map[string]string, error) {
+	infos := map[string]map[string]string{}
+	var tags map[string]string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+		if key == "processor" {
+			tags = map[string]string{}
+			infos["cpu"+value] = tags
+			continue
+		}
+		if tag, ok := cpuInfoTags[key]; ok && tags != nil && value != "" {
+			tags[tag] = value
+		}
+	}
+	return infos, scanner.Err()
+}
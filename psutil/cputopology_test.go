@@ -0,0 +1,94 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadCPUTopology(t *testing.T) {
+	Convey("Read cpu topology", t, func() {
+		os.Setenv("HOST_SYS", "testdata/sys")
+		os.Setenv("HOST_PROC", "testdata")
+		defer os.Unsetenv("HOST_SYS")
+		defer os.Unsetenv("HOST_PROC")
+
+		topology := readCPUTopology()
+		So(topology, ShouldHaveLength, 4)
+
+		Convey("of hyperthread siblings sharing a core", func() {
+			for _, name := range []string{"cpu0", "cpu1"} {
+				So(topology[name], ShouldResemble, map[string]string{
+					"socket":     "0",
+					"core":       "0",
+					"siblings":   "0-1",
+					"numa_node":  "0",
+					"model_name": "Intel(R) Xeon(R) Gold 6140 CPU @ 2.30GHz",
+					"vendor":     "GenuineIntel",
+					"microcode":  "0x2000065",
+				})
+			}
+		})
+
+		Convey("of a cpu on another package", func() {
+			So(topology["cpu2"]["socket"], ShouldEqual, "1")
+			So(topology["cpu2"]["siblings"], ShouldEqual, "2")
+			So(topology["cpu2"]["numa_node"], ShouldEqual, "1")
+		})
+
+		Convey("of an offline cpu, which has no topology", func() {
+			So(topology["cpu3"], ShouldBeEmpty)
+		})
+	})
+}
+
+func TestParseCPUInfoTags(t *testing.T) {
+	Convey("Parse model information from /proc/cpuinfo", t, func() {
+		f, err := os.Open("testdata/cpuinfo")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		infos, err := parseCPUInfoTags(f)
+		So(err, ShouldBeNil)
+		So(infos, ShouldHaveLength, 3)
+		So(infos["cpu2"], ShouldResemble, map[string]string{
+			"model_name": "Intel(R) Xeon(R) Gold 6140 CPU @ 2.30GHz",
+			"vendor":     "GenuineIntel",
+			"microcode":  "0x2000065",
+			"socket":     "1",
+			"core":       "0",
+		})
+
+		Convey("of ARM processors, which only report their number", func() {
+			f, err := os.Open("testdata/cpuinfo_arm")
+			So(err, ShouldBeNil)
+			defer f.Close()
+			infos, err := parseCPUInfoTags(f)
+			So(err, ShouldBeNil)
+			So(infos, ShouldResemble, map[string]map[string]string{"cpu0": {}, "cpu1": {}})
+		})
+	})
+}
@@ -19,14 +19,59 @@
 package psutil
 
 import (
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/shirou/gopsutil/disk"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
 )
 
+var diskLabels = map[string]label{
+	"total": label{
+		description: "total space of the file system mounted at the mount point",
+		unit:        unitBytes,
+	},
+	"used": label{
+		description: "space used on the file system mounted at the mount point",
+		unit:        unitBytes,
+	},
+	"free": label{
+		description: "space available to unprivileged users on the file system mounted at the mount point",
+		unit:        unitBytes,
+	},
+	"percent": label{
+		description: "percentage of the space usable by unprivileged users which is used",
+		unit:        unitPercent,
+	},
+	"responsive": label{
+		description: "1 if statfs of the mount point returned successfully within the probe timeout, 0 otherwise",
+		unit:        unitBoolean,
+	},
+	"probe_latency_ms": label{
+		description: "time taken by statfs of the mount point, the probe timeout if it did not return",
+		unit:        unitMilliseconds,
+	},
+}
+
+// pendingProbes tracks mount points whose statfs has not returned yet, so a
+// hung file system holds a single blocked goroutine instead of gaining a new
+// one on every collection
+var pendingProbes = struct {
+	sync.Mutex
+	mounts map[string]bool
+}{mounts: map[string]bool{}}
+
+// mountProbe is the outcome of a bounded-time statfs of a mount point
+type mountProbe struct {
+	usage   *disk.UsageStat
+	err     error
+	latency time.Duration
+}
+
 func getPSUtilDiskUsage(path string) (*disk.UsageStat, error) {
 	defer timeSpent(time.Now(), "getPSUtilDiskUsage")
 	disk_usage, err := disk.Usage(path)
@@ -36,7 +81,46 @@ func getPSUtilDiskUsage(path string) (*disk.UsageStat, error) {
 	return disk_usage, nil
 }
 
-func getDiskUsageMetrics(nss []plugin.Namespace, mounts []string) ([]plugin.Metric, error) {
+// statfs reads the usage of a mount point; tests replace it to simulate hung
+// file systems
+var statfs = getPSUtilDiskUsage
+
+// probeMount reads the usage of a mount point off the calling goroutine and
+// gives up after timeout, so stale NFS or FUSE mounts cannot block collection
+func probeMount(path string, timeout time.Duration) mountProbe {
+	pendingProbes.Lock()
+	if pendingProbes.mounts[path] {
+		pendingProbes.Unlock()
+		return mountProbe{
+			err:     fmt.Errorf("statfs of %s is still pending from a previous probe", path),
+			latency: timeout,
+		}
+	}
+	pendingProbes.mounts[path] = true
+	pendingProbes.Unlock()
+
+	done := make(chan mountProbe, 1)
+	start := time.Now()
+	go func() {
+		usage, err := statfs(path)
+		pendingProbes.Lock()
+		delete(pendingProbes.mounts, path)
+		pendingProbes.Unlock()
+		done <- mountProbe{usage: usage, err: err, latency: time.Since(start)}
+	}()
+
+	select {
+	case probe := <-done:
+		return probe
+	case <-time.After(timeout):
+		return mountProbe{
+			err:     fmt.Errorf("statfs of %s did not return within %s", path, timeout),
+			latency: timeout,
+		}
+	}
+}
+
+func getDiskUsageMetrics(nss []plugin.Namespace, mounts []string, probeTimeout time.Duration, units *unitConversion) ([]plugin.Metric, error) {
 	defer timeSpent(time.Now(), "getDiskUsageMetrics")
 	t := time.Now()
 	var paths []disk.PartitionStat
@@ -74,12 +158,49 @@ func getDiskUsageMetrics(nss []plugin.Namespace, mounts []string) ([]plugin.Metr
 	}
 
 	for _, path := range paths {
-		data, err := getPSUtilDiskUsage(path.Mountpoint)
-		if err != nil {
-			return nil, err
-		}
+		probe := probeMount(path.Mountpoint, probeTimeout)
 		tags := map[string]string{}
+		if bd := getBlockDevice(path.Device); bd != nil {
+			tags = bd.tags()
+		}
 		tags["device"] = path.Device
+		responsive := 1
+		if probe.err != nil {
+			responsive = 0
+		}
+		for _, namespace := range namespaces {
+			if strings.Contains(strings.Join(namespace, "|"), "responsive") {
+				nspace := make([]plugin.NamespaceElement, len(requested["responsive"]))
+				copy(nspace, requested["responsive"])
+				nspace[3].Value = path.Mountpoint
+				metrics = append(metrics, plugin.Metric{
+					Namespace: nspace,
+					Data:      responsive,
+					Tags:      tags,
+					Timestamp: t,
+					Unit:      diskLabels["responsive"].unit,
+				})
+			}
+			if strings.Contains(strings.Join(namespace, "|"), "probe_latency_ms") {
+				nspace := make([]plugin.NamespaceElement, len(requested["probe_latency_ms"]))
+				copy(nspace, requested["probe_latency_ms"])
+				nspace[3].Value = path.Mountpoint
+				metrics = append(metrics, plugin.Metric{
+					Namespace: nspace,
+					Data:      probe.latency.Seconds() * 1000,
+					Tags:      tags,
+					Timestamp: t,
+					Unit:      diskLabels["probe_latency_ms"].unit,
+				})
+			}
+		}
+		if probe.err != nil {
+			// skip usage of an unresponsive mount point rather than failing
+			// the whole disk subsystem
+			log.Warnf("skipping usage of mount point %s: %v", path.Mountpoint, probe.err)
+			continue
+		}
+		data := probe.usage
 		for _, namespace := range namespaces {
 			if strings.Contains(strings.Join(namespace, "|"), "total") {
 				nspace := make([]plugin.NamespaceElement, len(requested["total"]))
@@ -90,6 +211,7 @@ func getDiskUsageMetrics(nss []plugin.Namespace, mounts []string) ([]plugin.Metr
 					Data:      data.Total,
 					Tags:      tags,
 					Timestamp: t,
+					Unit:      diskLabels["total"].unit,
 				})
 			}
 			if strings.Contains(strings.Join(namespace, "|"), "used") {
@@ -101,6 +223,7 @@ func getDiskUsageMetrics(nss []plugin.Namespace, mounts []string) ([]plugin.Metr
 					Data:      data.Used,
 					Tags:      tags,
 					Timestamp: t,
+					Unit:      diskLabels["used"].unit,
 				})
 			}
 			if strings.Contains(strings.Join(namespace, "|"), "free") {
@@ -112,6 +235,7 @@ func getDiskUsageMetrics(nss []plugin.Namespace, mounts []string) ([]plugin.Metr
 					Data:      data.Free,
 					Tags:      tags,
 					Timestamp: t,
+					Unit:      diskLabels["free"].unit,
 				})
 			}
 			if strings.Contains(strings.Join(namespace, "|"), "percent") {
@@ -123,35 +247,25 @@ func getDiskUsageMetrics(nss []plugin.Namespace, mounts []string) ([]plugin.Metr
 					Data:      data.UsedPercent,
 					Tags:      tags,
 					Timestamp: t,
+					Unit:      diskLabels["percent"].unit,
 				})
 			}
 		}
 	}
-	return metrics, nil
+	return units.convert(metrics), nil
 }
 
 func getDiskUsageMetricTypes() []plugin.Metric {
 	defer timeSpent(time.Now(), "getDiskUsageMetricTypes")
 	var mts []plugin.Metric
-	mts = append(mts, plugin.Metric{
-		Namespace: plugin.NewNamespace("intel", "psutil", "disk").
-			AddDynamicElement("mount_point", "Mount Point").
-			AddStaticElement("total"),
-	})
-	mts = append(mts, plugin.Metric{
-		Namespace: plugin.NewNamespace("intel", "psutil", "disk").
-			AddDynamicElement("mount_point", "Mount Point").
-			AddStaticElement("used"),
-	})
-	mts = append(mts, plugin.Metric{
-		Namespace: plugin.NewNamespace("intel", "psutil", "disk").
-			AddDynamicElement("mount_point", "Mount Point").
-			AddStaticElement("free"),
-	})
-	mts = append(mts, plugin.Metric{
-		Namespace: plugin.NewNamespace("intel", "psutil", "disk").
-			AddDynamicElement("mount_point", "Mount Point").
-			AddStaticElement("percent"),
-	})
+	for k, label := range diskLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace: plugin.NewNamespace("intel", "psutil", "disk").
+				AddDynamicElement("mount_point", "Mount Point").
+				AddStaticElement(k),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
 	return mts
 }
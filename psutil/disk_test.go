@@ -0,0 +1,95 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package psutil
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/shirou/gopsutil/disk"
+)
+
+// stubStatfs replaces statfs with a stub which records the probed paths and
+// blocks on /mnt/hung until release is closed
+func stubStatfs() (calls chan string, release chan struct{}) {
+	calls = make(chan string, 10)
+	release = make(chan struct{})
+	statfs = func(path string) (*disk.UsageStat, error) {
+		calls <- path
+		if path == "/mnt/hung" {
+			<-release
+		}
+		return &disk.UsageStat{Path: path, Total: 1024}, nil
+	}
+	return calls, release
+}
+
+func TestProbeMount(t *testing.T) {
+	Convey("Probe a mount point in bounded time", t, func() {
+		defer func() { statfs = getPSUtilDiskUsage }()
+
+		Convey("of a responsive file system", func() {
+			stubStatfs()
+			probe := probeMount("/mnt/ok", time.Second)
+			So(probe.err, ShouldBeNil)
+			So(probe.usage.Total, ShouldEqual, uint64(1024))
+			So(probe.latency, ShouldBeLessThan, time.Second)
+		})
+
+		Convey("of a hung file system", func() {
+			calls, release := stubStatfs()
+			timeout := 20 * time.Millisecond
+			probe := probeMount("/mnt/hung", timeout)
+			So(probe.err, ShouldNotBeNil)
+			So(probe.err.Error(), ShouldContainSubstring, "did not return within")
+			So(probe.usage, ShouldBeNil)
+			So(probe.latency, ShouldEqual, timeout)
+			So(<-calls, ShouldEqual, "/mnt/hung")
+
+			// the blocked statfs is not started again
+			probe = probeMount("/mnt/hung", timeout)
+			So(probe.err, ShouldNotBeNil)
+			So(probe.err.Error(), ShouldContainSubstring, "still pending")
+			So(probe.latency, ShouldEqual, timeout)
+			So(calls, ShouldBeEmpty)
+
+			// nor does it hold up other mount points
+			So(probeMount("/mnt/ok", time.Second).err, ShouldBeNil)
+			So(<-calls, ShouldEqual, "/mnt/ok")
+
+			// once statfs returns, the mount point is probed again
+			close(release)
+			for pending := true; pending; {
+				time.Sleep(time.Millisecond)
+				pendingProbes.Lock()
+				pending = pendingProbes.mounts["/mnt/hung"]
+				pendingProbes.Unlock()
+			}
+			probe = probeMount("/mnt/hung", time.Second)
+			So(probe.err, ShouldBeNil)
+			So(probe.usage.Path, ShouldEqual, "/mnt/hung")
+		})
+	})
+}
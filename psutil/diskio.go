@@ -0,0 +1,229 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// diskstatsSectorSize is the unit of sector counts in /proc/diskstats, which
+// the kernel always reports in 512 byte sectors
+const diskstatsSectorSize = 512
+
+var diskIOLabels = map[string]label{
+	"read_count": label{
+		description: "number of reads completed",
+		unit:        unitCount,
+	},
+	"merged_read_count": label{
+		description: "number of adjacent reads merged into a single request",
+		unit:        unitCount,
+	},
+	"read_bytes": label{
+		description: "bytes read from the device",
+		unit:        unitBytes,
+	},
+	"read_time_ms": label{
+		description: "time spent by all reads",
+		unit:        unitMilliseconds,
+	},
+	"write_count": label{
+		description: "number of writes completed",
+		unit:        unitCount,
+	},
+	"merged_write_count": label{
+		description: "number of adjacent writes merged into a single request",
+		unit:        unitCount,
+	},
+	"write_bytes": label{
+		description: "bytes written to the device",
+		unit:        unitBytes,
+	},
+	"write_time_ms": label{
+		description: "time spent by all writes",
+		unit:        unitMilliseconds,
+	},
+	"io_in_progress": label{
+		description: "number of I/Os currently in progress",
+		unit:        unitCount,
+	},
+	"io_time_ms": label{
+		description: "time the device had I/Os in progress",
+		unit:        unitMilliseconds,
+	},
+	"weighted_io_time_ms": label{
+		description: "time spent doing I/Os weighted by the number of I/Os in progress",
+		unit:        unitMilliseconds,
+	},
+}
+
+// diskstatsFields lists the metrics of the fields of a /proc/diskstats line
+// following the major and minor numbers and the device name
+var diskstatsFields = []string{
+	"read_count",
+	"merged_read_count",
+	"read_bytes",
+	"read_time_ms",
+	"write_count",
+	"merged_write_count",
+	"write_bytes",
+	"write_time_ms",
+	"io_in_progress",
+	"io_time_ms",
+	"weighted_io_time_ms",
+}
+
+// diskStats holds the counters of a single line of /proc/diskstats
+type diskStats struct {
+	name   string
+	values map[string]uint64
+}
+
+func diskIOCounters(nss []plugin.Namespace, cfg plugin.Config, units *unitConversion) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "diskIOCounters")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(hostProc("diskstats"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	disks, err := parseDiskstats(f)
+	if err != nil {
+		return nil, err
+	}
+	friendlyNames := getFriendlyNames(cfg)
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, disk := range disks {
+		name := disk.name
+		tags := map[string]string{}
+		bd := getBlockDeviceByKernelName(disk.name)
+		if bd != nil {
+			tags = bd.tags()
+			if friendlyNames {
+				name = bd.friendlyName
+			}
+		}
+		for _, ns := range nss {
+			if ns[3].Value != "*" && ns[3].Value != name {
+				continue
+			}
+			metricName := ns.Element(len(ns) - 1).Value
+			label, ok := diskIOLabels[metricName]
+			if !ok {
+				label, ok = blockValueLabels[metricName]
+			}
+			if !ok {
+				return nil, fmt.Errorf("Requested disk I/O statistic %s is not available", metricName)
+			}
+			value, ok := disk.values[metricName]
+			if !ok {
+				// devices missing from /sys/class/block or without a
+				// request queue have no properties
+				if bd == nil {
+					continue
+				}
+				if value, ok = bd.values[metricName]; !ok {
+					continue
+				}
+			}
+			dyn := make([]plugin.NamespaceElement, len(ns))
+			copy(dyn, ns)
+			dyn[3].Value = name
+			results = append(results, plugin.Metric{
+				Namespace: dyn,
+				Data:      value,
+				Tags:      tags,
+				Timestamp: t,
+				Unit:      label.unit,
+			})
+		}
+	}
+
+	return units.convert(results), nil
+}
+
+// parseDiskstats parses /proc/diskstats, e.g.
+// "8 0 sda 4497 1224 253262 2192 1618 2071 53280 4116 0 3560 6304"; sector
+// counts are converted to bytes
+func parseDiskstats(r io.Reader) ([]diskStats, error) {
+	disks := []diskStats{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// partitions of kernels older than 2.6.25 only report 4 counters
+		if len(fields) < 3+len(diskstatsFields) {
+			continue
+		}
+		disk := diskStats{name: fields[2], values: map[string]uint64{}}
+		for i, metric := range diskstatsFields {
+			value, err := strconv.ParseUint(fields[3+i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid /proc/diskstats line: %s", scanner.Text())
+			}
+			if metric == "read_bytes" || metric == "write_bytes" {
+				value *= diskstatsSectorSize
+			}
+			disk.values[metric] = value
+		}
+		disks = append(disks, disk)
+	}
+	return disks, scanner.Err()
+}
+
+func getFriendlyNames(cfg plugin.Config) bool {
+	if enabled, err := cfg.GetBool("friendly_names"); err == nil {
+		return enabled
+	}
+	return false
+}
+
+func getDiskIOMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getDiskIOMetricTypes")
+	mts := []plugin.Metric{}
+	// /proc/diskstats is specific to Linux
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	for _, labels := range []map[string]label{diskIOLabels, blockValueLabels} {
+		for k, label := range labels {
+			mts = append(mts, plugin.Metric{
+				Namespace: plugin.NewNamespace("intel", "psutil", "diskio").
+					AddDynamicElement("device", "kernel name of the block device, or its device-mapper or md name with friendly_names").
+					AddStaticElement(k),
+				Description: label.description,
+				Unit:        label.unit,
+			})
+		}
+	}
+	return mts
+}
@@ -0,0 +1,112 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestParseDiskstats(t *testing.T) {
+	Convey("Parse /proc/diskstats", t, func() {
+		f, err := os.Open("testdata/diskstats")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		disks, err := parseDiskstats(f)
+		So(err, ShouldBeNil)
+		// sda2 only reports the counters of old kernels
+		So(len(disks), ShouldEqual, 5)
+		So(disks[0].name, ShouldEqual, "sda")
+		So(disks[0].values, ShouldResemble, map[string]uint64{
+			"read_count":          4497,
+			"merged_read_count":   1224,
+			"read_bytes":          253262 * 512,
+			"read_time_ms":        2192,
+			"write_count":         1618,
+			"merged_write_count":  2071,
+			"write_bytes":         53280 * 512,
+			"write_time_ms":       4116,
+			"io_in_progress":      0,
+			"io_time_ms":          3560,
+			"weighted_io_time_ms": 6304,
+		})
+	})
+}
+
+func TestDiskIOCounters(t *testing.T) {
+	Convey("Collect disk I/O counters", t, func() {
+		os.Setenv("HOST_PROC", "testdata")
+		os.Setenv("HOST_SYS", "testdata/sys")
+		os.Setenv("HOST_DEV", "testdata/dev")
+		defer os.Unsetenv("HOST_PROC")
+		defer os.Unsetenv("HOST_SYS")
+		defer os.Unsetenv("HOST_DEV")
+
+		nss := []plugin.Namespace{
+			plugin.NewNamespace("intel", "psutil", "diskio", "*", "read_count"),
+		}
+		units := &unitConversion{byteUnit: unitBytes, timeUnit: unitSeconds}
+
+		Convey("keyed by kernel name", func() {
+			metrics, err := diskIOCounters(nss, plugin.Config{}, units)
+			So(err, ShouldBeNil)
+			So(len(metrics), ShouldEqual, 5)
+			So(metrics[2].Namespace.Strings()[3], ShouldEqual, "dm-0")
+			So(metrics[2].Data, ShouldEqual, uint64(5316))
+			So(metrics[2].Tags["friendly_name"], ShouldEqual, "vg--data-root")
+			So(metrics[2].Tags["lvm_vg"], ShouldEqual, "vg-data")
+			So(metrics[2].Unit, ShouldEqual, unitCount)
+		})
+
+		Convey("keyed by friendly name", func() {
+			metrics, err := diskIOCounters([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "diskio", "data", "write_bytes"),
+			}, plugin.Config{"friendly_names": true}, units)
+			So(err, ShouldBeNil)
+			So(len(metrics), ShouldEqual, 1)
+			So(metrics[0].Data, ShouldEqual, uint64(32768*512))
+			So(metrics[0].Tags["kernel_name"], ShouldEqual, "md127")
+		})
+
+		Convey("together with device properties", func() {
+			metrics, err := diskIOCounters([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "diskio", "sda", "size_bytes"),
+				plugin.NewNamespace("intel", "psutil", "diskio", "sda", "read_ahead_kb"),
+			}, plugin.Config{}, units)
+			So(err, ShouldBeNil)
+			So(len(metrics), ShouldEqual, 2)
+			So(metrics[0].Data, ShouldEqual, uint64(1953525168*512))
+			So(metrics[0].Unit, ShouldEqual, unitBytes)
+			So(metrics[1].Data, ShouldEqual, uint64(128))
+			So(metrics[1].Unit, ShouldEqual, unitKibibytes)
+			// only descriptors are tags
+			So(metrics[0].Tags["scheduler"], ShouldEqual, "cfq")
+			So(metrics[0].Tags, ShouldNotContainKey, "size_bytes")
+			So(metrics[0].Tags, ShouldNotContainKey, "nr_requests")
+		})
+	})
+}
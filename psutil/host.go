@@ -0,0 +1,188 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/shirou/gopsutil/host"
+)
+
+var hostLabels = map[string]label{
+	"uptime": label{
+		description: "time elapsed since the host booted",
+		unit:        unitSeconds,
+	},
+	"boot_time": label{
+		description: "time the host booted at, in seconds since the epoch",
+		unit:        unitSeconds,
+	},
+	"users": label{
+		description: "number of users logged in",
+		unit:        unitCount,
+	},
+}
+
+// hostFactLabels describe the string facts about the host, which can also be
+// attached as tags to all metrics with the host_tags option
+var hostFactLabels = map[string]label{
+	"hostname": label{
+		description: "host name",
+		unit:        unitText,
+	},
+	"os": label{
+		description: "operating system, e.g. linux",
+		unit:        unitText,
+	},
+	"platform": label{
+		description: "platform (distribution), e.g. ubuntu",
+		unit:        unitText,
+	},
+	"platform_family": label{
+		description: "family of the platform, e.g. debian",
+		unit:        unitText,
+	},
+	"platform_version": label{
+		description: "version of the platform",
+		unit:        unitText,
+	},
+	"kernel_version": label{
+		description: "version of the running kernel",
+		unit:        unitText,
+	},
+	"virtualization_system": label{
+		description: "virtualization system the host runs on or provides, e.g. kvm or docker, empty on bare metal",
+		unit:        unitText,
+	},
+	"virtualization_role": label{
+		description: "virtualization role of the host, guest or host",
+		unit:        unitText,
+	},
+}
+
+// hostUsers lists the users logged in; tests replace it to simulate a
+// missing utmp
+var hostUsers = host.Users
+
+// hostInfo returns the requested host metrics from info, which is read once
+// per collection and shared with the host_tags option
+func hostInfo(nss []plugin.Namespace, info *host.InfoStat) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "hostInfo")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	facts := hostFacts(info)
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, ns := range nss {
+		metricName := ns.Element(len(ns) - 1).Value
+		var data interface{}
+		var unit string
+		switch metricName {
+		case "uptime":
+			data = info.Uptime
+			unit = hostLabels[metricName].unit
+		case "boot_time":
+			data = info.BootTime
+			unit = hostLabels[metricName].unit
+		case "users":
+			// read from utmp, which may be missing in containers
+			users, err := hostUsers()
+			if err != nil {
+				log.Warnf("skipping number of logged in users: %v", err)
+				continue
+			}
+			data = len(users)
+			unit = hostLabels[metricName].unit
+		default:
+			fact, ok := facts[metricName]
+			if !ok {
+				return nil, fmt.Errorf("Requested host statistic %s is not available", metricName)
+			}
+			data = fact
+			unit = hostFactLabels[metricName].unit
+		}
+		results = append(results, plugin.Metric{
+			Namespace: ns,
+			Data:      data,
+			Timestamp: t,
+			Unit:      unit,
+		})
+	}
+
+	return results, nil
+}
+
+// hostFacts returns the string facts about the host keyed by their metric
+// name
+func hostFacts(info *host.InfoStat) map[string]string {
+	return map[string]string{
+		"hostname":              info.Hostname,
+		"os":                    info.OS,
+		"platform":              info.Platform,
+		"platform_family":       info.PlatformFamily,
+		"platform_version":      info.PlatformVersion,
+		"kernel_version":        info.KernelVersion,
+		"virtualization_system": info.VirtualizationSystem,
+		"virtualization_role":   info.VirtualizationRole,
+	}
+}
+
+// addHostTags tags metrics with the facts about the host which are known,
+// leaving tags set by the subsystems untouched
+func addHostTags(metrics []plugin.Metric, facts map[string]string) {
+	for i := range metrics {
+		if metrics[i].Tags == nil {
+			metrics[i].Tags = map[string]string{}
+		}
+		for k, v := range facts {
+			if _, ok := metrics[i].Tags[k]; ok || v == "" {
+				continue
+			}
+			metrics[i].Tags[k] = v
+		}
+	}
+}
+
+func getHostTags(cfg plugin.Config) bool {
+	if enabled, err := cfg.GetBool("host_tags"); err == nil {
+		return enabled
+	}
+	return false
+}
+
+func getHostMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getHostMetricTypes")
+	mts := make([]plugin.Metric, 0, len(hostLabels)+len(hostFactLabels))
+	for _, labels := range []map[string]label{hostLabels, hostFactLabels} {
+		for k, label := range labels {
+			mts = append(mts, plugin.Metric{
+				Namespace:   plugin.NewNamespace("intel", "psutil", "host", k),
+				Description: label.description,
+				Unit:        label.unit,
+			})
+		}
+	}
+	return mts
+}
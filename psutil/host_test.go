@@ -0,0 +1,132 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/shirou/gopsutil/host"
+)
+
+func TestAddHostTags(t *testing.T) {
+	Convey("Tag metrics with facts about the host", t, func() {
+		facts := hostFacts(&host.InfoStat{
+			Hostname:           "node1",
+			OS:                 "linux",
+			Platform:           "ubuntu",
+			PlatformFamily:     "debian",
+			PlatformVersion:    "16.04",
+			KernelVersion:      "4.4.0-93-generic",
+			VirtualizationRole: "guest",
+		})
+		metrics := []plugin.Metric{
+			plugin.Metric{
+				Namespace: plugin.NewNamespace("intel", "psutil", "load", "load1"),
+			},
+			plugin.Metric{
+				Namespace: plugin.NewNamespace("intel", "psutil", "net", "eth0", "bytes_recv"),
+				Tags:      map[string]string{"mtu": "1500", "hostname": "eth0-alias"},
+			},
+		}
+		addHostTags(metrics, facts)
+		So(metrics[0].Tags, ShouldResemble, map[string]string{
+			"hostname":            "node1",
+			"os":                  "linux",
+			"platform":            "ubuntu",
+			"platform_family":     "debian",
+			"platform_version":    "16.04",
+			"kernel_version":      "4.4.0-93-generic",
+			"virtualization_role": "guest",
+		})
+		// tags set by the subsystem are kept
+		So(metrics[1].Tags["hostname"], ShouldEqual, "eth0-alias")
+		So(metrics[1].Tags["mtu"], ShouldEqual, "1500")
+		So(metrics[1].Tags["os"], ShouldEqual, "linux")
+	})
+
+	Convey("Host tags are disabled by default", t, func() {
+		So(getHostTags(plugin.Config{}), ShouldBeFalse)
+		So(getHostTags(plugin.Config{"host_tags": true}), ShouldBeTrue)
+	})
+}
+
+func TestHostInfo(t *testing.T) {
+	Convey("Report host metrics from a single read of the host facts", t, func() {
+		info := &host.InfoStat{
+			Hostname: "node1",
+			Platform: "ubuntu",
+			Uptime:   3600,
+			BootTime: 1500000000,
+		}
+		metrics, err := hostInfo([]plugin.Namespace{
+			plugin.NewNamespace("intel", "psutil", "host", "uptime"),
+			plugin.NewNamespace("intel", "psutil", "host", "boot_time"),
+			plugin.NewNamespace("intel", "psutil", "host", "platform"),
+		}, info)
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 3)
+		So(metrics[0].Data, ShouldEqual, uint64(3600))
+		So(metrics[0].Unit, ShouldEqual, unitSeconds)
+		So(metrics[1].Data, ShouldEqual, uint64(1500000000))
+		So(metrics[2].Data, ShouldEqual, "ubuntu")
+		So(metrics[2].Unit, ShouldEqual, unitText)
+
+		Convey("with the number of logged in users", func() {
+			hostUsers = func() ([]host.UserStat, error) {
+				return []host.UserStat{{User: "alice"}, {User: "bob"}}, nil
+			}
+			defer func() { hostUsers = host.Users }()
+			metrics, err := hostInfo([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "host", "users"),
+			}, info)
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Data, ShouldEqual, 2)
+			So(metrics[0].Unit, ShouldEqual, unitCount)
+		})
+
+		Convey("without failing when utmp cannot be read", func() {
+			hostUsers = func() ([]host.UserStat, error) {
+				return nil, errors.New("open /var/run/utmp: no such file or directory")
+			}
+			defer func() { hostUsers = host.Users }()
+			metrics, err := hostInfo([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "host", "uptime"),
+				plugin.NewNamespace("intel", "psutil", "host", "users"),
+			}, info)
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"intel", "psutil", "host", "uptime"})
+		})
+
+		Convey("of an unknown statistic", func() {
+			_, err := hostInfo([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "host", "kernel_arch"),
+			}, info)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,260 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+var hugepagesLabels = map[string]label{
+	"total": label{
+		description: "number of huge pages in the pool",
+		unit:        unitCount,
+	},
+	"free": label{
+		description: "number of huge pages in the pool not yet allocated",
+		unit:        unitCount,
+	},
+	"reserved": label{
+		description: "number of huge pages reserved for allocation but not yet allocated",
+		unit:        unitCount,
+	},
+	"surplus": label{
+		description: "number of huge pages above the pool size allocated through overcommit",
+		unit:        unitCount,
+	},
+}
+
+// hugepagesFiles maps metrics to the files of
+// /sys/kernel/mm/hugepages/hugepages-<size> they are read from
+var hugepagesFiles = map[string]string{
+	"total":    "nr_hugepages",
+	"free":     "free_hugepages",
+	"reserved": "resv_hugepages",
+	"surplus":  "surplus_hugepages",
+}
+
+var thpLabels = map[string]label{
+	"fault_alloc": label{
+		description: "number of page faults served with a transparent huge page since boot",
+		unit:        unitCount,
+	},
+	"fault_fallback": label{
+		description: "number of page faults which fell back to regular pages as no transparent huge page could be allocated since boot",
+		unit:        unitCount,
+	},
+	"collapse_alloc": label{
+		description: "number of regular pages collapsed into a transparent huge page by khugepaged since boot",
+		unit:        unitCount,
+	},
+	"collapse_alloc_failed": label{
+		description: "number of times khugepaged failed to allocate a transparent huge page to collapse pages into since boot",
+		unit:        unitCount,
+	},
+	"split_page": label{
+		description: "number of transparent huge pages split into regular pages since boot",
+		unit:        unitCount,
+	},
+	"split_page_failed": label{
+		description: "number of times splitting a transparent huge page failed since boot",
+		unit:        unitCount,
+	},
+}
+
+// hugepagesPool holds the statistics of the huge pages of a single size
+type hugepagesPool struct {
+	size     string
+	sizeKB   uint64
+	counters map[string]uint64
+}
+
+func hugepages(nss []plugin.Namespace) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "hugepages")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	pools, err := getHugepagesPools()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, ns := range nss {
+		metricName := ns.Element(len(ns) - 1).Value
+		if _, ok := hugepagesLabels[metricName]; !ok {
+			return nil, fmt.Errorf("Requested hugepages statistic %s is not available", metricName)
+		}
+		for _, pool := range pools {
+			if ns[3].Value != "*" && ns[3].Value != pool.size {
+				continue
+			}
+			dyn := make([]plugin.NamespaceElement, len(ns))
+			copy(dyn, ns)
+			dyn[3].Value = pool.size
+			results = append(results, plugin.Metric{
+				Namespace: dyn,
+				Data:      pool.counters[metricName],
+				Tags:      map[string]string{"page_size_bytes": strconv.FormatUint(pool.sizeKB*1024, 10)},
+				Timestamp: t,
+				Unit:      hugepagesLabels[metricName].unit,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// getHugepagesPools reads the pool of each huge page size from
+// /sys/kernel/mm/hugepages, e.g. hugepages-2048kB
+func getHugepagesPools() ([]hugepagesPool, error) {
+	dirs, err := filepath.Glob(hostSys("kernel", "mm", "hugepages", "hugepages-*kB"))
+	if err != nil {
+		return nil, err
+	}
+	pools := []hugepagesPool{}
+	for _, dir := range dirs {
+		size := strings.TrimPrefix(filepath.Base(dir), "hugepages-")
+		sizeKB, err := strconv.ParseUint(strings.TrimSuffix(size, "kB"), 10, 64)
+		if err != nil {
+			continue
+		}
+		pool := hugepagesPool{size: size, sizeKB: sizeKB, counters: map[string]uint64{}}
+		for metric, file := range hugepagesFiles {
+			pool.counters[metric], _ = strconv.ParseUint(readSysfsString(filepath.Join(dir, file)), 10, 64)
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+func transparentHugepages(nss []plugin.Namespace) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "transparentHugepages")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(hostProc("vmstat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	counters, err := parseTHPCounters(f)
+	if err != nil {
+		return nil, err
+	}
+	tags := getTHPModes()
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, ns := range nss {
+		metricName := ns.Element(len(ns) - 1).Value
+		if _, ok := thpLabels[metricName]; !ok {
+			return nil, fmt.Errorf("Requested THP statistic %s is not available", metricName)
+		}
+		value, ok := counters[metricName]
+		if !ok {
+			// kernels without THP support have no counters
+			continue
+		}
+		results = append(results, plugin.Metric{
+			Namespace: ns,
+			Data:      value,
+			Tags:      tags,
+			Timestamp: t,
+			Unit:      thpLabels[metricName].unit,
+		})
+	}
+
+	return results, nil
+}
+
+// getTHPModes returns the modes selected in
+// /sys/kernel/mm/transparent_hugepage, e.g. "always [madvise] never", which is
+// the format of the I/O scheduler selection
+func getTHPModes() map[string]string {
+	tags := map[string]string{}
+	for tag, file := range map[string]string{
+		"thp_enabled": "enabled",
+		"thp_defrag":  "defrag",
+	} {
+		if mode := activeScheduler(readSysfsString(hostSys("kernel", "mm", "transparent_hugepage", file))); mode != "" {
+			tags[tag] = mode
+		}
+	}
+	return tags
+}
+
+// parseTHPCounters parses the thp_* counters of /proc/vmstat, keyed by name
+// without the thp_ prefix
+func parseTHPCounters(r io.Reader) (map[string]uint64, error) {
+	counters := map[string]uint64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || !strings.HasPrefix(fields[0], "thp_") {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s value in /proc/vmstat: %s", fields[0], fields[1])
+		}
+		counters[strings.TrimPrefix(fields[0], "thp_")] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
+func getHugepagesMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getHugepagesMetricTypes")
+	mts := []plugin.Metric{}
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	for k, label := range hugepagesLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace: plugin.NewNamespace("intel", "psutil", "hugepages").
+				AddDynamicElement("page_size", "huge page size (2048kB, 1048576kB, ...)").
+				AddStaticElement(k),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
+	for k, label := range thpLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace:   plugin.NewNamespace("intel", "psutil", "thp", k),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
+	return mts
+}
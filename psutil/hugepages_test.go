@@ -0,0 +1,73 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetHugepagesPools(t *testing.T) {
+	Convey("Read huge page pools", t, func() {
+		os.Setenv("HOST_SYS", "testdata/sys")
+		defer os.Unsetenv("HOST_SYS")
+
+		pools, err := getHugepagesPools()
+		So(err, ShouldBeNil)
+		So(len(pools), ShouldEqual, 2)
+		So(pools[1], ShouldResemble, hugepagesPool{
+			size:   "2048kB",
+			sizeKB: 2048,
+			counters: map[string]uint64{
+				"total":    1024,
+				"free":     512,
+				"reserved": 16,
+				"surplus":  0,
+			},
+		})
+
+		Convey("with THP modes", func() {
+			So(getTHPModes(), ShouldResemble, map[string]string{
+				"thp_enabled": "madvise",
+				"thp_defrag":  "madvise",
+			})
+		})
+	})
+}
+
+func TestParseTHPCounters(t *testing.T) {
+	Convey("Parse THP counters of /proc/vmstat", t, func() {
+		f, err := os.Open("testdata/vmstat")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		counters, err := parseTHPCounters(f)
+		So(err, ShouldBeNil)
+		So(len(counters), ShouldEqual, 10)
+		So(counters["fault_alloc"], ShouldEqual, uint64(39274))
+		So(counters["fault_fallback"], ShouldEqual, uint64(1829))
+		So(counters["collapse_alloc_failed"], ShouldEqual, uint64(7))
+		So(counters["split_page_failed"], ShouldEqual, uint64(3))
+	})
+}
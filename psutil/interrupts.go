@@ -0,0 +1,262 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// irq holds the counters of a single line of /proc/interrupts
+type irq struct {
+	name   string
+	counts map[string]uint64
+	total  uint64
+	chip   string
+	kind   string
+	device string
+}
+
+// interruptsFilter restricts collected interrupts to the configured IRQs and
+// devices
+type interruptsFilter struct {
+	irqs       map[string]bool
+	devices    *regexp.Regexp
+	totalsOnly bool
+}
+
+func interrupts(nss []plugin.Namespace, cfg plugin.Config) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "interrupts")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	filter, err := getInterruptsFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(hostProc("interrupts"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	irqs, cpus, err := parseInterrupts(f)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, ns := range nss {
+		if len(ns) != 6 || ns[5].Value != "count" {
+			return nil, fmt.Errorf("Requested interrupts statistic %s is not available", strings.Join(ns.Strings()[3:], "/"))
+		}
+		for _, irq := range irqs {
+			if !filter.match(&irq) || (ns[3].Value != "*" && ns[3].Value != irq.name) {
+				continue
+			}
+			tags := irq.tags()
+			// per-cpu counters are replaced by the total when only totals
+			// are requested
+			if ns[4].Value == "total" || (filter.totalsOnly && ns[4].IsDynamic()) {
+				dyn := make([]plugin.NamespaceElement, len(ns))
+				copy(dyn, ns)
+				dyn[3].Value = irq.name
+				dyn[4].Value = "total"
+				results = append(results, plugin.Metric{
+					Namespace: dyn,
+					Data:      irq.total,
+					Tags:      tags,
+					Timestamp: t,
+					Unit:      unitCount,
+				})
+				continue
+			}
+			for _, cpu := range cpus {
+				count, ok := irq.counts[cpu]
+				if !ok || (ns[4].Value != "*" && ns[4].Value != cpu) {
+					continue
+				}
+				dyn := make([]plugin.NamespaceElement, len(ns))
+				copy(dyn, ns)
+				dyn[3].Value = irq.name
+				dyn[4].Value = cpu
+				results = append(results, plugin.Metric{
+					Namespace: dyn,
+					Data:      count,
+					Tags:      cpuTags(cpu, tags),
+					Timestamp: t,
+					Unit:      unitCount,
+				})
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func getInterruptsFilter(cfg plugin.Config) (*interruptsFilter, error) {
+	filter := &interruptsFilter{}
+	if irqs, err := cfg.GetString("irqs"); err == nil && irqs != "" && irqs != "*" {
+		filter.irqs = map[string]bool{}
+		for _, name := range strings.Split(irqs, "|") {
+			filter.irqs[strings.TrimSpace(name)] = true
+		}
+	}
+	if devices, err := cfg.GetString("devices"); err == nil && devices != "" {
+		re, err := regexp.Compile(devices)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid devices expression %q: %v", devices, err)
+		}
+		filter.devices = re
+	}
+	if totalsOnly, err := cfg.GetBool("totals_only"); err == nil {
+		filter.totalsOnly = totalsOnly
+	}
+	return filter, nil
+}
+
+func (f *interruptsFilter) match(irq *irq) bool {
+	if f.irqs != nil && !f.irqs[irq.name] {
+		return false
+	}
+	if f.devices != nil && !f.devices.MatchString(irq.device) {
+		return false
+	}
+	return true
+}
+
+func (i *irq) tags() map[string]string {
+	tags := map[string]string{"device": i.device}
+	if i.chip != "" {
+		tags["chip"] = i.chip
+	}
+	if i.kind != "" {
+		tags["type"] = i.kind
+	}
+	return tags
+}
+
+// parseInterrupts parses /proc/interrupts and returns the IRQs in the order
+// they are listed, together with the names of the cpus (e.g. cpu0) of the
+// columns; offline cpus have no column
+func parseInterrupts(r io.Reader) ([]irq, []string, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("/proc/interrupts is empty")
+	}
+	cpus := []string{}
+	for _, column := range strings.Fields(scanner.Text()) {
+		cpus = append(cpus, strings.ToLower(column))
+	}
+
+	irqs := []irq{}
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasSuffix(fields[0], ":") {
+			continue
+		}
+		i := irq{
+			name:   strings.TrimSuffix(fields[0], ":"),
+			counts: map[string]uint64{},
+		}
+		n := 0
+		// summary lines such as ERR and MIS have a single count
+		for ; n < len(cpus) && n+1 < len(fields); n++ {
+			count, err := strconv.ParseUint(fields[n+1], 10, 64)
+			if err != nil {
+				break
+			}
+			i.counts[cpus[n]] = count
+			i.total += count
+		}
+		i.chip, i.kind, i.device = parseIRQDescription(i.name, fields[n+1:])
+		irqs = append(irqs, i)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return irqs, cpus, nil
+}
+
+// parseIRQDescription splits the text following the counters into the
+// interrupt controller, trigger type and devices, e.g.
+// "IO-APIC 16-fasteoi ehci_hcd:usb1, i801_smbus" or, on older kernels,
+// "IO-APIC-edge timer". Architecture specific interrupts (NMI, LOC, ...)
+// only have a description, which is reported as device.
+func parseIRQDescription(name string, fields []string) (string, string, string) {
+	if _, err := strconv.Atoi(name); err != nil || len(fields) == 0 {
+		return "", "", strings.Join(fields, " ")
+	}
+	chip, rest := fields[0], fields[1:]
+	kind := ""
+	if len(rest) > 1 {
+		if idx := strings.LastIndex(rest[0], "-"); idx > 0 {
+			if _, err := strconv.Atoi(rest[0][:idx]); err == nil {
+				kind = rest[0][idx+1:]
+				rest = rest[1:]
+			}
+		}
+	}
+	if kind == "" {
+		for _, trigger := range []string{"-edge", "-level", "-fasteoi"} {
+			if strings.HasSuffix(chip, trigger) {
+				chip, kind = strings.TrimSuffix(chip, trigger), trigger[1:]
+			}
+		}
+	}
+	return chip, kind, strings.Join(rest, " ")
+}
+
+func getInterruptsMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getInterruptsMetricTypes")
+	mts := []plugin.Metric{}
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	mts = append(mts, plugin.Metric{
+		Namespace: plugin.NewNamespace("intel", "psutil", "interrupts").
+			AddDynamicElement("irq", "interrupt number or name").
+			AddDynamicElement("cpu_id", "physical cpu id").
+			AddStaticElement("count"),
+		Description: "number of interrupts serviced by given cpu since boot",
+		Unit:        unitCount,
+	})
+	mts = append(mts, plugin.Metric{
+		Namespace: plugin.NewNamespace("intel", "psutil", "interrupts").
+			AddDynamicElement("irq", "interrupt number or name").
+			AddStaticElement("total").
+			AddStaticElement("count"),
+		Description: "number of interrupts serviced by all cpus since boot",
+		Unit:        unitCount,
+	})
+	return mts
+}
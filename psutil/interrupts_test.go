@@ -0,0 +1,123 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseInterrupts(t *testing.T) {
+	Convey("Parse /proc/interrupts", t, func() {
+		f, err := os.Open("testdata/interrupts")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		irqs, cpus, err := parseInterrupts(f)
+		So(err, ShouldBeNil)
+		So(cpus, ShouldResemble, []string{"cpu0", "cpu1", "cpu2", "cpu3"})
+		So(irqs, ShouldHaveLength, 11)
+
+		Convey("device interrupts", func() {
+			So(irqs[3].name, ShouldEqual, "16")
+			So(irqs[3].chip, ShouldEqual, "IO-APIC")
+			So(irqs[3].kind, ShouldEqual, "fasteoi")
+			So(irqs[3].device, ShouldEqual, "ehci_hcd:usb1, i801_smbus")
+			So(irqs[3].total, ShouldEqual, uint64(1240))
+
+			So(irqs[4].device, ShouldEqual, "eth0-TxRx-0")
+			So(irqs[4].kind, ShouldEqual, "edge")
+			So(irqs[4].counts["cpu3"], ShouldEqual, uint64(117002))
+		})
+		Convey("architecture specific interrupts", func() {
+			So(irqs[7].name, ShouldEqual, "LOC")
+			So(irqs[7].device, ShouldEqual, "Local timer interrupts")
+			So(irqs[7].chip, ShouldEqual, "")
+			So(irqs[9].name, ShouldEqual, "ERR")
+			So(irqs[9].counts, ShouldHaveLength, 1)
+		})
+		Convey("filtered by IRQ or device", func() {
+			filter, err := getInterruptsFilter(plugin.Config{"irqs": "0|LOC"})
+			So(err, ShouldBeNil)
+			So(filter.match(&irqs[0]), ShouldBeTrue)
+			So(filter.match(&irqs[4]), ShouldBeFalse)
+			So(filter.match(&irqs[7]), ShouldBeTrue)
+
+			filter, err = getInterruptsFilter(plugin.Config{"devices": "^eth0-"})
+			So(err, ShouldBeNil)
+			So(filter.match(&irqs[4]), ShouldBeTrue)
+			So(filter.match(&irqs[5]), ShouldBeTrue)
+			So(filter.match(&irqs[0]), ShouldBeFalse)
+		})
+	})
+	Convey("Parse IRQ description of older kernels", t, func() {
+		chip, kind, device := parseIRQDescription("1", []string{"IO-APIC-edge", "i8042"})
+		So(chip, ShouldEqual, "IO-APIC")
+		So(kind, ShouldEqual, "edge")
+		So(device, ShouldEqual, "i8042")
+	})
+}
+
+func TestInterrupts(t *testing.T) {
+	Convey("Collect interrupts from /proc/interrupts", t, func() {
+		os.Setenv("HOST_PROC", "testdata")
+		defer os.Unsetenv("HOST_PROC")
+
+		Convey("per cpu", func() {
+			metrics, err := interrupts([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "interrupts", "16", "*", "count"),
+			}, plugin.Config{})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 4)
+			So(metrics[2].Namespace.Strings(), ShouldResemble, []string{"intel", "psutil", "interrupts", "16", "cpu2", "count"})
+			So(metrics[2].Data, ShouldEqual, uint64(33))
+			So(metrics[2].Tags["device"], ShouldEqual, "ehci_hcd:usb1, i801_smbus")
+		})
+		Convey("over all cpus", func() {
+			metrics, err := interrupts([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "interrupts", "16", "total", "count"),
+			}, plugin.Config{})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Data, ShouldEqual, uint64(1240))
+		})
+		Convey("as totals only", func() {
+			metrics, err := interrupts([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "interrupts", "16").
+					AddDynamicElement("cpu_id", "physical cpu id").
+					AddStaticElement("count"),
+			}, plugin.Config{"totals_only": true})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"intel", "psutil", "interrupts", "16", "total", "count"})
+		})
+		Convey("without the count leaf", func() {
+			_, err := interrupts([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "interrupts", "16", "cpu0"),
+			}, plugin.Config{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,277 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/shirou/gopsutil/cpu"
+)
+
+// userHZ is the unit of times in /proc/stat, which the kernel always reports
+// in USER_HZ (1/100th of a second) regardless of its internal tick rate
+const userHZ = 100
+
+var kernelLabels = map[string]label{
+	"context_switches": label{
+		description: "number of context switches since boot",
+		unit:        unitCount,
+	},
+	"interrupts": label{
+		description: "number of interrupts serviced since boot",
+		unit:        unitCount,
+	},
+	"forks": label{
+		description: "number of processes and threads created since boot",
+		unit:        unitCount,
+	},
+	"procs_running": label{
+		description: "number of processes in runnable state",
+		unit:        unitCount,
+	},
+	"procs_blocked": label{
+		description: "number of processes blocked waiting for I/O to complete",
+		unit:        unitCount,
+	},
+	"boot_time": label{
+		description: "time at which the system booted, in seconds since the Epoch",
+		unit:        unitSeconds,
+	},
+}
+
+// kernelRateLabels describe the rates of kernel counters, requested with a
+// trailing per_sec element, e.g. /intel/psutil/kernel/forks/per_sec
+var kernelRateLabels = map[string]label{
+	"context_switches": label{
+		description: "context switches per second since the previous collection",
+		unit:        unitPerSecond,
+	},
+	"interrupts": label{
+		description: "interrupts serviced per second since the previous collection",
+		unit:        unitPerSecond,
+	},
+	"forks": label{
+		description: "processes and threads created per second since the previous collection",
+		unit:        unitPerSecond,
+	},
+}
+
+// procStat holds the content of /proc/stat
+type procStat struct {
+	cpuTotal     cpu.TimesStat
+	cpus         []cpu.TimesStat
+	ctxt         uint64
+	intr         uint64
+	processes    uint64
+	procsRunning uint64
+	procsBlocked uint64
+	btime        uint64
+	at           time.Time
+}
+
+// readProcStat reads /proc/stat; it is only available on Linux, elsewhere
+// cpu times are read with gopsutil and nil is returned
+func readProcStat() (*procStat, error) {
+	if runtime.GOOS != "linux" {
+		return nil, nil
+	}
+	defer timeSpent(time.Now(), "readProcStat")
+	f, err := os.Open(hostProc("stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseProcStat(f)
+}
+
+// parseProcStat parses cpu times, the same way gopsutil does, together with
+// the kernel counters of /proc/stat
+func parseProcStat(r io.Reader) (*procStat, error) {
+	stat := &procStat{at: time.Now()}
+	scanner := bufio.NewScanner(r)
+	// the intr line holds a counter for every interrupt and gets long
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "cpu") {
+			times, err := parseProcStatCPU(fields)
+			if err != nil {
+				return nil, err
+			}
+			if times.CPU == "cpu-total" {
+				stat.cpuTotal = *times
+			} else {
+				stat.cpus = append(stat.cpus, *times)
+			}
+			continue
+		}
+		var dest *uint64
+		switch fields[0] {
+		case "ctxt":
+			dest = &stat.ctxt
+		case "intr":
+			dest = &stat.intr
+		case "processes":
+			dest = &stat.processes
+		case "procs_running":
+			dest = &stat.procsRunning
+		case "procs_blocked":
+			dest = &stat.procsBlocked
+		case "btime":
+			dest = &stat.btime
+		default:
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s value in /proc/stat: %s", fields[0], fields[1])
+		}
+		*dest = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stat, nil
+}
+
+// parseProcStatCPU parses a cpu line: user nice system idle iowait irq
+// softirq steal guest guest_nice, the last three only on newer kernels
+func parseProcStatCPU(fields []string) (*cpu.TimesStat, error) {
+	if len(fields) < 8 {
+		return nil, fmt.Errorf("Invalid cpu line in /proc/stat: %s", strings.Join(fields, " "))
+	}
+	values := make([]float64, 10)
+	for i := 1; i < len(fields) && i <= len(values); i++ {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cpu line in /proc/stat: %s", strings.Join(fields, " "))
+		}
+		values[i-1] = value / userHZ
+	}
+	name := fields[0]
+	if name == "cpu" {
+		name = "cpu-total"
+	}
+	return &cpu.TimesStat{
+		CPU:       name,
+		User:      values[0],
+		Nice:      values[1],
+		System:    values[2],
+		Idle:      values[3],
+		Iowait:    values[4],
+		Irq:       values[5],
+		Softirq:   values[6],
+		Steal:     values[7],
+		Guest:     values[8],
+		GuestNice: values[9],
+	}, nil
+}
+
+func kernelStats(nss []plugin.Namespace, stat *procStat) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "kernelStats")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	if stat == nil {
+		return nil, fmt.Errorf("kernel statistics are not available on %s", runtime.GOOS)
+	}
+
+	results := []plugin.Metric{}
+
+	for _, ns := range nss {
+		metricName := ns[3].Value
+		rate := len(ns) == 5 && ns[4].Value == "per_sec"
+		if len(ns) != 4 && !rate {
+			return nil, fmt.Errorf("Requested kernel statistic %s is not found", strings.Join(ns.Strings()[3:], "/"))
+		}
+		var counter uint64
+		switch metricName {
+		case "context_switches":
+			counter = stat.ctxt
+		case "interrupts":
+			counter = stat.intr
+		case "forks":
+			counter = stat.processes
+		case "procs_running":
+			counter = stat.procsRunning
+		case "procs_blocked":
+			counter = stat.procsBlocked
+		case "boot_time":
+			counter = stat.btime
+		default:
+			return nil, fmt.Errorf("Requested kernel statistic %s is not found", metricName)
+		}
+		var data interface{} = counter
+		unit := kernelLabels[metricName].unit
+		if rate {
+			label, ok := kernelRateLabels[metricName]
+			if !ok {
+				return nil, fmt.Errorf("Requested kernel statistic %s/per_sec is not found", metricName)
+			}
+			perSec, ok := counterRate(ns.String(), float64(counter), stat.at)
+			if !ok {
+				// no rate until the counter was sampled twice
+				continue
+			}
+			data, unit = perSec, label.unit
+		}
+		results = append(results, plugin.Metric{
+			Namespace: ns,
+			Data:      data,
+			Timestamp: stat.at,
+			Unit:      unit,
+		})
+	}
+
+	return results, nil
+}
+
+func getKernelMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getKernelMetricTypes")
+	mts := []plugin.Metric{}
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	for name, label := range kernelLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace:   plugin.NewNamespace("intel", "psutil", "kernel", name),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
+	for name, label := range kernelRateLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace:   plugin.NewNamespace("intel", "psutil", "kernel", name, "per_sec"),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
+	return mts
+}
@@ -0,0 +1,110 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func TestParseProcStat(t *testing.T) {
+	Convey("Parse /proc/stat", t, func() {
+		f, err := os.Open("testdata/proc_stat")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		stat, err := parseProcStat(f)
+		So(err, ShouldBeNil)
+
+		Convey("cpu times are in seconds", func() {
+			So(stat.cpuTotal.CPU, ShouldEqual, "cpu-total")
+			So(stat.cpuTotal.User, ShouldEqual, 22.55)
+			So(stat.cpuTotal.Idle, ShouldEqual, 226255.63)
+			So(stat.cpus, ShouldHaveLength, 2)
+			So(stat.cpus[1].CPU, ShouldEqual, "cpu1")
+			So(stat.cpus[1].System, ShouldEqual, 8.49)
+			So(stat.cpus[1].Softirq, ShouldEqual, 0.18)
+		})
+		Convey("kernel counters", func() {
+			So(stat.ctxt, ShouldEqual, uint64(1990473))
+			So(stat.intr, ShouldEqual, uint64(114930548))
+			So(stat.processes, ShouldEqual, uint64(2915))
+			So(stat.procsRunning, ShouldEqual, uint64(1))
+			So(stat.procsBlocked, ShouldEqual, uint64(0))
+			So(stat.btime, ShouldEqual, uint64(1062191376))
+		})
+	})
+}
+
+func TestCounterRate(t *testing.T) {
+	Convey("Counter rate", t, func() {
+		start := time.Now()
+		_, ok := counterRate("test", 100, start)
+		So(ok, ShouldBeFalse)
+		rate, ok := counterRate("test", 300, start.Add(2*time.Second))
+		So(ok, ShouldBeTrue)
+		So(rate, ShouldEqual, 100.0)
+		Convey("is not reported after a counter reset", func() {
+			_, ok := counterRate("test", 10, start.Add(3*time.Second))
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+func TestKernelStats(t *testing.T) {
+	Convey("Kernel statistics", t, func() {
+		start := time.Now()
+		counterSamples.Lock()
+		counterSamples.last = map[string]counterSample{}
+		counterSamples.Unlock()
+		nss := []plugin.Namespace{
+			plugin.NewNamespace("intel", "psutil", "kernel", "forks"),
+			plugin.NewNamespace("intel", "psutil", "kernel", "forks", "per_sec"),
+		}
+
+		metrics, err := kernelStats(nss, &procStat{processes: 1000, at: start})
+		So(err, ShouldBeNil)
+		// no rate on the first sample
+		So(metrics, ShouldHaveLength, 1)
+		So(metrics[0].Data, ShouldEqual, uint64(1000))
+		So(metrics[0].Unit, ShouldEqual, unitCount)
+
+		metrics, err = kernelStats(nss, &procStat{processes: 1050, at: start.Add(5 * time.Second)})
+		So(err, ShouldBeNil)
+		So(metrics, ShouldHaveLength, 2)
+		So(metrics[1].Namespace.Strings(), ShouldResemble, []string{"intel", "psutil", "kernel", "forks", "per_sec"})
+		So(metrics[1].Data, ShouldEqual, 10.0)
+		So(metrics[1].Unit, ShouldEqual, unitPerSecond)
+
+		Convey("only counters have a rate", func() {
+			_, err := kernelStats([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "kernel", "procs_running", "per_sec"),
+			}, &procStat{at: start})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
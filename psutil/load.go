@@ -20,12 +20,39 @@ package psutil
 
 import (
 	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/load"
 )
 
+var loadavgLabels = map[string]label{
+	"procs_running": label{
+		description: "number of currently runnable kernel scheduling entities (processes, threads)",
+		unit:        unitCount,
+	},
+	"procs_total": label{
+		description: "number of kernel scheduling entities (processes, threads) that currently exist on the system",
+		unit:        unitCount,
+	},
+	"last_pid": label{
+		description: "PID of the process that was most recently created on the system",
+		unit:        unitIdentifier,
+	},
+}
+
+// loadavg holds the fields of /proc/loadavg following the load averages
+type loadavg struct {
+	procsRunning uint64
+	procsTotal   uint64
+	lastPid      uint64
+}
+
 func loadAvg(nss []plugin.Namespace) ([]plugin.Metric, error) {
 	defer timeSpent(time.Now(), "loadAvg")
 	load, err := load.Avg()
@@ -33,29 +60,78 @@ func loadAvg(nss []plugin.Namespace) ([]plugin.Metric, error) {
 		return nil, err
 	}
 
+	// the cpu count and /proc/loadavg are only read when metrics derived
+	// from them are requested
+	var cores int
+	var extended *loadavg
+
 	results := make([]plugin.Metric, len(nss))
 
 	for i, ns := range nss {
-		switch ns.Element(len(ns) - 1).Value {
+		metricName := ns.Element(len(ns) - 1).Value
+		switch metricName {
 		case "load1":
 			results[i] = plugin.Metric{
 				Namespace: ns,
 				Data:      load.Load1,
-				Unit:      "Load/1M",
+				Unit:      unitLoad1,
 				Timestamp: time.Now(),
 			}
 		case "load5":
 			results[i] = plugin.Metric{
 				Namespace: ns,
 				Data:      load.Load5,
-				Unit:      "Load/5M",
+				Unit:      unitLoad5,
 				Timestamp: time.Now(),
 			}
 		case "load15":
 			results[i] = plugin.Metric{
 				Namespace: ns,
 				Data:      load.Load15,
-				Unit:      "Load/15M",
+				Unit:      unitLoad15,
+				Timestamp: time.Now(),
+			}
+		case "load1_per_core", "load5_per_core", "load15_per_core":
+			if cores == 0 {
+				cores, err = cpu.Counts(true)
+				if err != nil {
+					return nil, err
+				}
+				if cores == 0 {
+					return nil, fmt.Errorf("Number of online cpus is not available")
+				}
+			}
+			avgs := map[string]struct {
+				value float64
+				unit  string
+			}{
+				"load1_per_core":  {load.Load1, unitLoad1},
+				"load5_per_core":  {load.Load5, unitLoad5},
+				"load15_per_core": {load.Load15, unitLoad15},
+			}
+			results[i] = plugin.Metric{
+				Namespace: ns,
+				Data:      avgs[metricName].value / float64(cores),
+				Unit:      avgs[metricName].unit,
+				Tags:      map[string]string{"cores": strconv.Itoa(cores)},
+				Timestamp: time.Now(),
+			}
+		case "procs_running", "procs_total", "last_pid":
+			if extended == nil {
+				extended, err = readLoadavg()
+				if err != nil {
+					return nil, err
+				}
+			}
+			fields := map[string]uint64{
+				"procs_running": extended.procsRunning,
+				"procs_total":   extended.procsTotal,
+				"last_pid":      extended.lastPid,
+			}
+			results[i] = plugin.Metric{
+				Namespace: ns,
+				Data:      fields[metricName],
+				Unit:      loadavgLabels[metricName].unit,
 				Timestamp: time.Now(),
 			}
 		default:
@@ -66,15 +142,74 @@ func loadAvg(nss []plugin.Namespace) ([]plugin.Metric, error) {
 	return results, nil
 }
 
+func readLoadavg() (*loadavg, error) {
+	content, err := ioutil.ReadFile(hostProc("loadavg"))
+	if err != nil {
+		return nil, err
+	}
+	return parseLoadavg(string(content))
+}
+
+// parseLoadavg parses the fields of /proc/loadavg following the load
+// averages, e.g. "0.20 0.18 0.12 1/80 11206"
+func parseLoadavg(content string) (*loadavg, error) {
+	fields := strings.Fields(content)
+	if len(fields) < 5 {
+		return nil, fmt.Errorf("Invalid /proc/loadavg content: %s", content)
+	}
+	entities := strings.Split(fields[3], "/")
+	if len(entities) != 2 {
+		return nil, fmt.Errorf("Invalid /proc/loadavg content: %s", content)
+	}
+	values := make([]uint64, 3)
+	for i, field := range []string{entities[0], entities[1], fields[4]} {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid /proc/loadavg content: %s", content)
+		}
+		values[i] = value
+	}
+	return &loadavg{
+		procsRunning: values[0],
+		procsTotal:   values[1],
+		lastPid:      values[2],
+	}, nil
+}
+
 func getLoadAvgMetricTypes() []plugin.Metric {
 	defer timeSpent(time.Now(), "getLoadAvgMetricTypes")
-	t := []int{1, 5, 15}
-	mts := make([]plugin.Metric, len(t))
-	for i, te := range t {
-		mts[i] = plugin.Metric{
-			Namespace: plugin.NewNamespace("intel", "psutil", "load", fmt.Sprintf("load%d", te)),
-			Unit:      fmt.Sprintf("Load/%dM", te),
-		}
+	t := []struct {
+		minutes int
+		period  string
+		unit    string
+	}{
+		{1, "1 minute", unitLoad1},
+		{5, "5 minutes", unitLoad5},
+		{15, "15 minutes", unitLoad15},
+	}
+	mts := make([]plugin.Metric, 0, 2*len(t)+len(loadavgLabels))
+	for _, te := range t {
+		mts = append(mts, plugin.Metric{
+			Namespace:   plugin.NewNamespace("intel", "psutil", "load", fmt.Sprintf("load%d", te.minutes)),
+			Description: fmt.Sprintf("load average over the last %s", te.period),
+			Unit:        te.unit,
+		})
+		mts = append(mts, plugin.Metric{
+			Namespace:   plugin.NewNamespace("intel", "psutil", "load", fmt.Sprintf("load%d_per_core", te.minutes)),
+			Description: fmt.Sprintf("load average over the last %s divided by the number of online cpus", te.period),
+			Unit:        te.unit,
+		})
+	}
+	// /proc/loadavg is specific to Linux
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	for k, label := range loadavgLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace:   plugin.NewNamespace("intel", "psutil", "load", k),
+			Description: label.description,
+			Unit:        label.unit,
+		})
 	}
 	return mts
 }
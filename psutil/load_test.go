@@ -0,0 +1,43 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseLoadavg(t *testing.T) {
+	Convey("Parse /proc/loadavg", t, func() {
+		avg, err := parseLoadavg("0.20 0.18 0.12 3/812 11206\n")
+		So(err, ShouldBeNil)
+		So(*avg, ShouldResemble, loadavg{procsRunning: 3, procsTotal: 812, lastPid: 11206})
+	})
+
+	Convey("Parse invalid /proc/loadavg", t, func() {
+		for _, content := range []string{"", "0.20 0.18 0.12 3 11206", "0.20 0.18 0.12 3/x 11206"} {
+			_, err := parseLoadavg(content)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,294 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+var mdraidLabels = map[string]label{
+	"level": label{
+		description: "RAID level of the array (raid0, raid1, raid5, ...)",
+		unit:        unitText,
+	},
+	"disks_total": label{
+		description: "number of devices the array is configured with",
+		unit:        unitCount,
+	},
+	"disks_active": label{
+		description: "number of devices of the array which are in sync",
+		unit:        unitCount,
+	},
+	"disks_failed": label{
+		description: "number of member devices marked as faulty",
+		unit:        unitCount,
+	},
+	"disks_spare": label{
+		description: "number of spare member devices",
+		unit:        unitCount,
+	},
+	"sync_percent": label{
+		description: "progress of running resync, recovery, reshape or check; 100 when the array is idle",
+		unit:        unitPercent,
+	},
+	"sync_speed": label{
+		description: "speed of running resync, recovery, reshape or check",
+		unit:        unitBytesPerSecond,
+	},
+	"degraded": label{
+		description: "number of devices missing from the array, 0 for a healthy array",
+		unit:        unitCount,
+	},
+}
+
+var (
+	mdstatArrayRe  = regexp.MustCompile(`^(md\S*)\s*:\s*(\S+)\s*(.*)$`)
+	mdstatDisksRe  = regexp.MustCompile(`\[(\d+)/(\d+)\]`)
+	mdstatSyncRe   = regexp.MustCompile(`(resync|recovery|reshape|check|repair)\s*=\s*(?:([\d.]+)%|(\S+))`)
+	mdstatSpeedRe  = regexp.MustCompile(`speed=(\d+)K/sec`)
+	mdstatMemberRe = regexp.MustCompile(`^\S+\[\d+\]((?:\([A-Z]\))*)$`)
+)
+
+// mdArray holds the state of a software RAID array
+type mdArray struct {
+	name        string
+	state       string
+	level       string
+	disksTotal  int64
+	disksActive int64
+	disksFailed int64
+	disksSpare  int64
+	syncAction  string
+	syncPercent float64
+	syncSpeed   uint64
+	degraded    int64
+}
+
+func mdraidStats(nss []plugin.Namespace) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "mdraidStats")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	arrays, err := getMdArrays()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []plugin.Metric{}
+
+	for _, ns := range nss {
+		// set requested metric name from last namespace element
+		metricName := ns.Element(len(ns) - 1).Value
+		for _, array := range arrays {
+			if ns[3].Value != "*" && ns[3].Value != array.name {
+				continue
+			}
+			// prepare namespace copy to update value
+			// this will allow to keep namespace as dynamic (name != "")
+			dyn := make([]plugin.NamespaceElement, len(ns))
+			copy(dyn, ns)
+			dyn[3].Value = array.name
+			val, err := getMdArrayValue(&array, metricName)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, plugin.Metric{
+				Namespace: dyn,
+				Data:      val,
+				Tags:      array.tags(),
+				Timestamp: time.Now(),
+				Unit:      mdraidLabels[metricName].unit,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// getMdArrays reads /proc/mdstat and completes it with the array state
+// exposed in /sys/block/md*/md; there are no arrays when md_mod is not
+// loaded and /proc/mdstat does not exist
+func getMdArrays() ([]mdArray, error) {
+	f, err := os.Open(hostProc("mdstat"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	arrays, err := parseMdstat(f)
+	if err != nil {
+		return nil, err
+	}
+	for i := range arrays {
+		md := hostSys("block", arrays[i].name, "md")
+		if degraded, err := strconv.ParseInt(readSysfsString(md+"/degraded"), 10, 64); err == nil {
+			arrays[i].degraded = degraded
+		}
+		if action := readSysfsString(md + "/sync_action"); action != "" {
+			arrays[i].syncAction = action
+		}
+		if state := readSysfsString(md + "/array_state"); state != "" {
+			arrays[i].state = state
+		}
+	}
+	return arrays, nil
+}
+
+// parseMdstat parses the content of /proc/mdstat
+func parseMdstat(r io.Reader) ([]mdArray, error) {
+	arrays := []mdArray{}
+	var array *mdArray
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			array = nil
+			continue
+		}
+		if m := mdstatArrayRe.FindStringSubmatch(line); m != nil {
+			arrays = append(arrays, parseMdstatArray(m[1], m[2], strings.Fields(m[3])))
+			array = &arrays[len(arrays)-1]
+			continue
+		}
+		if array == nil {
+			continue
+		}
+		if m := mdstatDisksRe.FindStringSubmatch(line); m != nil {
+			array.disksTotal, _ = strconv.ParseInt(m[1], 10, 64)
+			array.disksActive, _ = strconv.ParseInt(m[2], 10, 64)
+			array.degraded = array.disksTotal - array.disksActive
+		}
+		if m := mdstatSyncRe.FindStringSubmatch(line); m != nil {
+			array.syncAction = m[1]
+			array.syncPercent = 0
+			if m[2] != "" {
+				percent, err := strconv.ParseFloat(m[2], 64)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid %s progress for %s: %s", m[1], array.name, line)
+				}
+				array.syncPercent = percent
+			}
+		}
+		if m := mdstatSpeedRe.FindStringSubmatch(line); m != nil {
+			speed, _ := strconv.ParseUint(m[1], 10, 64)
+			array.syncSpeed = speed * 1024
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return arrays, nil
+}
+
+// parseMdstatArray parses the header line of an array, e.g.
+// "md0 : active raid1 sdb1[1] sda1[0](F)"
+func parseMdstatArray(name, state string, fields []string) mdArray {
+	array := mdArray{
+		name:  name,
+		state: state,
+	}
+	if state == "active" {
+		array.syncPercent = 100
+	}
+	var members int64
+	for _, field := range fields {
+		m := mdstatMemberRe.FindStringSubmatch(field)
+		if m == nil {
+			// read-only markers and the raid level precede the members
+			if !strings.HasPrefix(field, "(") && array.level == "" {
+				array.level = field
+			}
+			continue
+		}
+		switch {
+		case strings.Contains(m[1], "(F)"):
+			array.disksFailed++
+		case strings.Contains(m[1], "(S)"):
+			array.disksSpare++
+		default:
+			members++
+		}
+	}
+	// arrays without redundancy (raid0, linear) and inactive arrays do not
+	// report the [total/active] status, count their members instead
+	array.disksTotal = members + array.disksFailed
+	array.disksActive = members
+	array.degraded = array.disksFailed
+	return array
+}
+
+func (a *mdArray) tags() map[string]string {
+	tags := map[string]string{}
+	if bd := getBlockDevice("/dev/" + a.name); bd != nil {
+		tags = bd.tags()
+	}
+	tags["state"] = a.state
+	if a.syncAction != "" {
+		tags["sync_action"] = a.syncAction
+	}
+	return tags
+}
+
+func getMdArrayValue(array *mdArray, name string) (interface{}, error) {
+	switch name {
+	case "level":
+		return array.level, nil
+	case "disks_total":
+		return array.disksTotal, nil
+	case "disks_active":
+		return array.disksActive, nil
+	case "disks_failed":
+		return array.disksFailed, nil
+	case "disks_spare":
+		return array.disksSpare, nil
+	case "sync_percent":
+		return array.syncPercent, nil
+	case "sync_speed":
+		return array.syncSpeed, nil
+	case "degraded":
+		return array.degraded, nil
+	default:
+		return nil, fmt.Errorf("Requested mdraid statistic %s is not available", name)
+	}
+}
+
+func getMdraidMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getMdraidMetricTypes")
+	mts := []plugin.Metric{}
+	for name, label := range mdraidLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace: plugin.NewNamespace("intel", "psutil", "mdraid").
+				AddDynamicElement("array", "md array name").AddStaticElement(name),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
+	return mts
+}
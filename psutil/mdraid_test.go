@@ -0,0 +1,132 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func parseMdstatFixture(name string) ([]mdArray, error) {
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMdstat(f)
+}
+
+func TestParseMdstat(t *testing.T) {
+	Convey("Parse /proc/mdstat", t, func() {
+		Convey("with an array being resynced", func() {
+			arrays, err := parseMdstatFixture("mdstat_resync")
+			So(err, ShouldBeNil)
+			So(arrays, ShouldHaveLength, 2)
+
+			So(arrays[0].name, ShouldEqual, "md127")
+			So(arrays[0].state, ShouldEqual, "active")
+			So(arrays[0].level, ShouldEqual, "raid1")
+			So(arrays[0].disksTotal, ShouldEqual, int64(2))
+			So(arrays[0].disksActive, ShouldEqual, int64(2))
+			So(arrays[0].disksFailed, ShouldEqual, int64(0))
+			So(arrays[0].degraded, ShouldEqual, int64(0))
+			So(arrays[0].syncAction, ShouldEqual, "resync")
+			So(arrays[0].syncPercent, ShouldEqual, 27.4)
+			So(arrays[0].syncSpeed, ShouldEqual, uint64(199498*1024))
+
+			So(arrays[1].name, ShouldEqual, "md0")
+			So(arrays[1].level, ShouldEqual, "raid5")
+			So(arrays[1].disksTotal, ShouldEqual, int64(3))
+			So(arrays[1].syncAction, ShouldEqual, "")
+			So(arrays[1].syncPercent, ShouldEqual, 100.0)
+			So(arrays[1].syncSpeed, ShouldEqual, uint64(0))
+		})
+		Convey("with a degraded array being recovered", func() {
+			arrays, err := parseMdstatFixture("mdstat_recovery")
+			So(err, ShouldBeNil)
+			So(arrays, ShouldHaveLength, 2)
+
+			So(arrays[0].name, ShouldEqual, "md1")
+			So(arrays[0].level, ShouldEqual, "raid6")
+			So(arrays[0].disksTotal, ShouldEqual, int64(5))
+			So(arrays[0].disksActive, ShouldEqual, int64(4))
+			So(arrays[0].disksSpare, ShouldEqual, int64(1))
+			So(arrays[0].degraded, ShouldEqual, int64(1))
+			So(arrays[0].syncAction, ShouldEqual, "recovery")
+			So(arrays[0].syncPercent, ShouldEqual, 8.5)
+			So(arrays[0].syncSpeed, ShouldEqual, uint64(150984*1024))
+
+			So(arrays[1].name, ShouldEqual, "md2")
+			So(arrays[1].syncAction, ShouldEqual, "resync")
+			So(arrays[1].syncPercent, ShouldEqual, 0.0)
+			So(arrays[1].syncSpeed, ShouldEqual, uint64(0))
+		})
+		Convey("with failed, read-only, striped and inactive arrays", func() {
+			arrays, err := parseMdstatFixture("mdstat_failed")
+			So(err, ShouldBeNil)
+			So(arrays, ShouldHaveLength, 4)
+
+			So(arrays[0].name, ShouldEqual, "md126")
+			So(arrays[0].disksTotal, ShouldEqual, int64(2))
+			So(arrays[0].disksActive, ShouldEqual, int64(1))
+			So(arrays[0].disksFailed, ShouldEqual, int64(1))
+			So(arrays[0].degraded, ShouldEqual, int64(1))
+
+			So(arrays[1].name, ShouldEqual, "md125")
+			So(arrays[1].level, ShouldEqual, "raid10")
+			So(arrays[1].disksActive, ShouldEqual, int64(4))
+			So(arrays[1].degraded, ShouldEqual, int64(0))
+
+			So(arrays[2].name, ShouldEqual, "md124")
+			So(arrays[2].level, ShouldEqual, "raid0")
+			So(arrays[2].disksTotal, ShouldEqual, int64(2))
+			So(arrays[2].disksActive, ShouldEqual, int64(2))
+
+			So(arrays[3].name, ShouldEqual, "md123")
+			So(arrays[3].state, ShouldEqual, "inactive")
+			So(arrays[3].level, ShouldEqual, "")
+			So(arrays[3].disksSpare, ShouldEqual, int64(2))
+			So(arrays[3].syncPercent, ShouldEqual, 0.0)
+		})
+	})
+}
+
+func TestMdraidStatsWithoutMdstat(t *testing.T) {
+	Convey("Collect md arrays on a host without md_mod loaded", t, func() {
+		os.Setenv("HOST_PROC", "testdata/dev")
+		defer os.Unsetenv("HOST_PROC")
+
+		arrays, err := getMdArrays()
+		So(err, ShouldBeNil)
+		So(arrays, ShouldBeEmpty)
+
+		metrics, err := mdraidStats([]plugin.Namespace{
+			plugin.NewNamespace("intel", "psutil", "mdraid", "*", "degraded"),
+		})
+		So(err, ShouldBeNil)
+		So(metrics, ShouldBeEmpty)
+	})
+}
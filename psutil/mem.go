@@ -26,7 +26,50 @@ import (
 	"github.com/shirou/gopsutil/mem"
 )
 
-func virtualMemory(nss []plugin.Namespace) ([]plugin.Metric, error) {
+var virtualMemoryLabels = map[string]label{
+	"total": label{
+		description: "total physical memory",
+		unit:        unitBytes,
+	},
+	"available": label{
+		description: "memory that can be given instantly to processes without the system going into swap; it is calculated by summing different memory values depending on the platform (e.g. free + buffers + cached on Linux)",
+		unit:        unitBytes,
+	},
+	"used": label{
+		description: "memory used, calculated differently depending on the platform and designed for informational purposes only",
+		unit:        unitBytes,
+	},
+	"used_percent": label{
+		description: "percentage of memory used, calculated as (total - available) / total * 100",
+		unit:        unitPercent,
+	},
+	"free": label{
+		description: "memory not being used at all (zeroed) that is readily available; note that this does not reflect the actual memory available (use available instead)",
+		unit:        unitBytes,
+	},
+	"active": label{
+		description: "memory currently in use or very recently used, and so it is in RAM (UNIX)",
+		unit:        unitBytes,
+	},
+	"inactive": label{
+		description: "memory that is marked as not used (UNIX)",
+		unit:        unitBytes,
+	},
+	"buffers": label{
+		description: "cache for things like file system metadata (Linux, BSD)",
+		unit:        unitBytes,
+	},
+	"cached": label{
+		description: "cache for various things (Linux, BSD)",
+		unit:        unitBytes,
+	},
+	"wired": label{
+		description: "memory that is marked to always stay in RAM, it is never moved to disk (BSD, OSX)",
+		unit:        unitBytes,
+	},
+}
+
+func virtualMemory(nss []plugin.Namespace, units *unitConversion) ([]plugin.Metric, error) {
 	defer timeSpent(time.Now(), "virtualMemory")
 	mem, err := mem.VirtualMemory()
 	if err != nil {
@@ -38,7 +81,8 @@ func virtualMemory(nss []plugin.Namespace) ([]plugin.Metric, error) {
 	for i, ns := range nss {
 		var data interface{}
 
-		switch ns.Element(len(ns) - 1).Value {
+		metricName := ns.Element(len(ns) - 1).Value
+		switch metricName {
 		case "total":
 			data = mem.Total
 		case "available":
@@ -66,77 +110,23 @@ func virtualMemory(nss []plugin.Namespace) ([]plugin.Metric, error) {
 		results[i] = plugin.Metric{
 			Namespace: ns,
 			Data:      data,
-			Unit:      "B",
+			Unit:      virtualMemoryLabels[metricName].unit,
 			Timestamp: time.Now(),
 		}
 	}
 
-	return results, nil
+	return units.convert(results), nil
 }
 
 func getVirtualMemoryMetricTypes() []plugin.Metric {
 	defer timeSpent(time.Now(), "getVirtualMemoryMetricTypes")
-	return []plugin.Metric{
-		plugin.Metric{
-			Namespace:   plugin.NewNamespace("intel", "psutil", "vm", "total"),
-			Unit:        "B",
-			Description: "total swap memory in bytes",
-		},
-		plugin.Metric{
-			Namespace: plugin.NewNamespace("intel", "psutil", "vm", "available"),
-			Unit:      "B",
-			Description: `the actual amount of available memory that can be 
-			given instantly to processes that request more memory in bytes; 
-			this is calculated by summing different memory values depending 
-			on the platform (e.g. free + buffers + cached on Linux) and it 
-			is supposed to be used to monitor actual memory usage in a cross 
-			platform fashion.`,
-		},
-		plugin.Metric{
-			Namespace: plugin.NewNamespace("intel", "psutil", "vm", "used"),
-			Unit:      "B",
-			Description: `Memory used is calculated differently depending on 
-			the platform and designed for informational purposes only.`,
-		},
-		plugin.Metric{
-			Namespace: plugin.NewNamespace("intel", "psutil", "vm", "used_percent"),
-			Unit:      "B",
-			Description: `the percentage usage calculated as (total - available) 
-			/ total * 100.`,
-		},
-		plugin.Metric{
-			Namespace: plugin.NewNamespace("intel", "psutil", "vm", "free"),
-			Description: `memory not being used at all (zeroed) that is readily 
-			available; note that this doesn’t reflect the actual memory available 
-			(use ‘available’ instead).`,
-			Unit: "B",
-		},
-		plugin.Metric{
-			Namespace: plugin.NewNamespace("intel", "psutil", "vm", "active"),
-			Description: `(UNIX): memory currently in use or very recently used, 
-			and so it is in RAM.`,
-			Unit: "B",
-		},
-		plugin.Metric{
-			Namespace:   plugin.NewNamespace("intel", "psutil", "vm", "inactive"),
-			Description: `(UNIX): memory that is marked as not used.`,
-			Unit:        "B",
-		},
-		plugin.Metric{
-			Namespace:   plugin.NewNamespace("intel", "psutil", "vm", "buffers"),
-			Description: `(Linux, BSD): cache for things like file system metadata.`,
-			Unit:        "B",
-		},
-		plugin.Metric{
-			Namespace:   plugin.NewNamespace("intel", "psutil", "vm", "cached"),
-			Description: `(Linux, BSD): cache for various things.`,
-			Unit:        "B",
-		},
-		plugin.Metric{
-			Namespace: plugin.NewNamespace("intel", "psutil", "vm", "wired"),
-			Description: `(BSD, OSX): memory that is marked to always stay in RAM. 
-			It is never moved to disk.`,
-			Unit: "B",
-		},
+	mts := []plugin.Metric{}
+	for k, label := range virtualMemoryLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace:   plugin.NewNamespace("intel", "psutil", "vm", k),
+			Description: label.description,
+			Unit:        label.unit,
+		})
 	}
+	return mts
 }
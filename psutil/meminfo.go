@@ -0,0 +1,181 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// defaultMeminfoFields are the /proc/meminfo fields collected when no fields
+// are configured
+var defaultMeminfoFields = []string{
+	"MemTotal", "MemFree", "MemAvailable", "Buffers", "Cached", "SwapCached",
+	"Active", "Inactive", "SwapTotal", "SwapFree", "Dirty", "Writeback",
+	"AnonPages", "Mapped", "Shmem", "Slab", "SReclaimable", "SUnreclaim",
+	"KernelStack", "PageTables", "CommitLimit", "Committed_AS",
+	"HugePages_Total", "HugePages_Free", "HugePages_Rsvd", "HugePages_Surp",
+	"Hugepagesize",
+}
+
+// meminfoField is a single line of /proc/meminfo
+type meminfoField struct {
+	name  string
+	value uint64
+	unit  string
+}
+
+func meminfo(nss []plugin.Namespace, cfg plugin.Config) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "meminfo")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	filter, err := getMeminfoFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(hostProc("meminfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fields, err := parseMeminfo(f)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, ns := range nss {
+		if len(ns) != 5 || ns[4].Value != "value" {
+			return nil, fmt.Errorf("Requested meminfo statistic %s is not available", strings.Join(ns.Strings()[3:], "/"))
+		}
+		for _, field := range fields {
+			// explicitly requested fields are collected regardless of the
+			// configured selection
+			if ns[3].Value == "*" && !filter.MatchString(field.name) {
+				continue
+			}
+			if ns[3].Value != "*" && ns[3].Value != field.name {
+				continue
+			}
+			dyn := make([]plugin.NamespaceElement, len(ns))
+			copy(dyn, ns)
+			dyn[3].Value = field.name
+			results = append(results, plugin.Metric{
+				Namespace: dyn,
+				Data:      field.value,
+				Timestamp: t,
+				Unit:      field.unit,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// getMeminfoFilter returns the expression matching the field names to collect,
+// which is either configured with the fields option or the default set
+func getMeminfoFilter(cfg plugin.Config) (*regexp.Regexp, error) {
+	expr := strings.Join(defaultMeminfoFields, "|")
+	if fields, err := cfg.GetString("fields"); err == nil && fields != "" {
+		expr = fields
+		if fields == "*" {
+			expr = ".*"
+		}
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid fields expression %q: %v", expr, err)
+	}
+	return re, nil
+}
+
+// parseMeminfo parses /proc/meminfo, e.g. "Active(anon):  1024 kB", or the
+// meminfo of a NUMA node, e.g. "Node 0 Active(anon):  1024 kB", into fields in
+// the order they are listed. Values in kB are converted to bytes and
+// parentheses are replaced in names so that they fit in a namespace, e.g.
+// Active(anon) becomes Active_anon.
+func parseMeminfo(r io.Reader) ([]meminfoField, error) {
+	fields := []meminfoField{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		values := strings.Fields(parts[1])
+		if len(values) == 0 {
+			continue
+		}
+		value, err := strconv.ParseUint(values[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s value in meminfo: %s", parts[0], parts[1])
+		}
+		field := meminfoField{
+			name:  meminfoFieldName(parts[0]),
+			value: value,
+			unit:  unitCount,
+		}
+		if len(values) > 1 && values[1] == "kB" {
+			field.value *= 1024
+			field.unit = unitBytes
+		}
+		fields = append(fields, field)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func meminfoFieldName(name string) string {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return ""
+	}
+	// drop the "Node N" prefix of NUMA node meminfo
+	return strings.NewReplacer("(", "_", ")", "").Replace(words[len(words)-1])
+}
+
+func getMeminfoMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getMeminfoMetricTypes")
+	mts := []plugin.Metric{}
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	mts = append(mts, plugin.Metric{
+		Namespace: plugin.NewNamespace("intel", "psutil", "meminfo").
+			AddDynamicElement("field", "name of the /proc/meminfo field (MemTotal, Dirty, Active_anon, HugePages_Total, ...)").
+			AddStaticElement("value"),
+		Description: "value of the /proc/meminfo field, in bytes for fields reported in kB; fields without unit such as HugePages_Total are counts",
+		Unit:        unitBytes,
+	})
+	return mts
+}
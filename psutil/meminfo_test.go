@@ -0,0 +1,117 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseMeminfo(t *testing.T) {
+	Convey("Parse /proc/meminfo", t, func() {
+		f, err := os.Open("testdata/meminfo")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		fields, err := parseMeminfo(f)
+		So(err, ShouldBeNil)
+		So(len(fields), ShouldEqual, 48)
+		So(fields[0], ShouldResemble, meminfoField{name: "MemTotal", value: 16318744 * 1024, unit: "B"})
+
+		Convey("with parentheses removed from names", func() {
+			So(fields[8].name, ShouldEqual, "Active_anon")
+		})
+
+		Convey("with counts reported without unit", func() {
+			So(fields[40], ShouldResemble, meminfoField{name: "HugePages_Total", value: 16, unit: "count"})
+		})
+	})
+}
+
+func TestGetMeminfoFilter(t *testing.T) {
+	Convey("Select meminfo fields", t, func() {
+		Convey("by default", func() {
+			filter, err := getMeminfoFilter(plugin.Config{})
+			So(err, ShouldBeNil)
+			So(filter.MatchString("Dirty"), ShouldBeTrue)
+			So(filter.MatchString("Committed_AS"), ShouldBeTrue)
+			So(filter.MatchString("DirectMap4k"), ShouldBeFalse)
+			So(filter.MatchString("MemTotalX"), ShouldBeFalse)
+		})
+
+		Convey("by name or expression", func() {
+			filter, err := getMeminfoFilter(plugin.Config{"fields": "Dirty|DirectMap.*"})
+			So(err, ShouldBeNil)
+			So(filter.MatchString("Dirty"), ShouldBeTrue)
+			So(filter.MatchString("DirectMap2M"), ShouldBeTrue)
+			So(filter.MatchString("MemTotal"), ShouldBeFalse)
+		})
+
+		Convey("all of them", func() {
+			filter, err := getMeminfoFilter(plugin.Config{"fields": "*"})
+			So(err, ShouldBeNil)
+			So(filter.MatchString("VmallocChunk"), ShouldBeTrue)
+		})
+
+		Convey("with an invalid expression", func() {
+			_, err := getMeminfoFilter(plugin.Config{"fields": "Dirty("})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestMeminfo(t *testing.T) {
+	Convey("Collect fields of /proc/meminfo", t, func() {
+		os.Setenv("HOST_PROC", "testdata")
+		defer os.Unsetenv("HOST_PROC")
+
+		Convey("selected with the fields option", func() {
+			metrics, err := meminfo([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "meminfo", "*", "value"),
+			}, plugin.Config{"fields": "Dirty|HugePages_Total"})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 2)
+			So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"intel", "psutil", "meminfo", "Dirty", "value"})
+			So(metrics[0].Data, ShouldEqual, uint64(1228*1024))
+			So(metrics[0].Unit, ShouldEqual, unitBytes)
+			So(metrics[1].Data, ShouldEqual, uint64(16))
+			So(metrics[1].Unit, ShouldEqual, unitCount)
+		})
+		Convey("requested explicitly", func() {
+			metrics, err := meminfo([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "meminfo", "Active_anon", "value"),
+			}, plugin.Config{"fields": "Dirty"})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 1)
+			So(metrics[0].Data, ShouldEqual, uint64(5322228*1024))
+		})
+		Convey("without the value leaf", func() {
+			_, err := meminfo([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "meminfo", "Dirty"),
+			}, plugin.Config{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
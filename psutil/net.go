@@ -30,40 +30,40 @@ import (
 
 var netIOCounterLabels = map[string]label{
 	"bytes_sent": label{
-		unit:        "",
-		description: "",
+		unit:        unitBytes,
+		description: "number of bytes sent",
 	},
 	"bytes_recv": label{
-		unit:        "",
-		description: "",
+		unit:        unitBytes,
+		description: "number of bytes received",
 	},
 	"packets_sent": label{
-		unit:        "",
-		description: "",
+		unit:        unitCount,
+		description: "number of packets sent",
 	},
 	"packets_recv": label{
-		unit:        "",
-		description: "",
+		unit:        unitCount,
+		description: "number of packets received",
 	},
 	"errin": label{
-		unit:        "",
-		description: "",
+		unit:        unitCount,
+		description: "total number of errors while receiving",
 	},
 	"errout": label{
-		unit:        "",
-		description: "",
+		unit:        unitCount,
+		description: "total number of errors while sending",
 	},
 	"dropin": label{
-		unit:        "",
-		description: "",
+		unit:        unitCount,
+		description: "total number of incoming packets which were dropped",
 	},
 	"dropout": label{
-		unit:        "",
-		description: "",
+		unit:        unitCount,
+		description: "total number of outgoing packets which were dropped (always 0 on OSX and BSD)",
 	},
 }
 
-func netIOCounters(nss []plugin.Namespace) ([]plugin.Metric, error) {
+func netIOCounters(nss []plugin.Namespace, units *unitConversion) ([]plugin.Metric, error) {
 	defer timeSpent(time.Now(), "netIOCounters")
 	// gather accumulated metrics for all interfaces
 	netsAll, err := psutilnet.IOCounters(false)
@@ -144,7 +144,7 @@ func netIOCounters(nss []plugin.Namespace) ([]plugin.Metric, error) {
 		}
 	}
 
-	return results, nil
+	return units.convert(results), nil
 }
 
 func findNetIOStats(nets []psutilnet.IOCountersStat, name string) *psutilnet.IOCountersStat {
@@ -187,14 +187,14 @@ func getNetIOCounterMetricTypes() ([]plugin.Metric, error) {
 		//metrics which are the sum for all available nics
 		mts = append(mts, plugin.Metric{
 			Namespace:   plugin.NewNamespace("intel", "psutil", "net", "all", name),
-			Description: label.description,
+			Description: label.description + " on all interfaces",
 			Unit:        label.unit,
 		})
 		//dynamic metrics representing any nic
 		mts = append(mts, plugin.Metric{
 			Namespace: plugin.NewNamespace("intel", "psutil", "net").
 				AddDynamicElement("interface_name", "network interface name").AddStaticElement(name),
-			Description: label.description,
+			Description: label.description + " on given interface",
 			Unit:        label.unit,
 		})
 	}
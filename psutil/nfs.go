@@ -0,0 +1,364 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+var nfsLabels = map[string]label{
+	"bytes_read": label{
+		description: "bytes read by applications from the mount, through the page cache or with O_DIRECT",
+		unit:        unitBytes,
+	},
+	"bytes_written": label{
+		description: "bytes written by applications to the mount, through the page cache or with O_DIRECT",
+		unit:        unitBytes,
+	},
+	"server_bytes_read": label{
+		description: "bytes read from the server with READ requests",
+		unit:        unitBytes,
+	},
+	"server_bytes_written": label{
+		description: "bytes written to the server with WRITE requests",
+		unit:        unitBytes,
+	},
+	"ops": label{
+		description: "RPC requests completed for the mount",
+		unit:        unitCount,
+	},
+	"retransmissions": label{
+		description: "RPC requests transmitted more than once for the mount",
+		unit:        unitCount,
+	},
+	"timeouts": label{
+		description: "major timeouts of RPC requests for the mount",
+		unit:        unitCount,
+	},
+}
+
+var nfsOpLabels = map[string]label{
+	"ops": label{
+		description: "requests of given RPC type completed",
+		unit:        unitCount,
+	},
+	"retransmissions": label{
+		description: "requests of given RPC type transmitted more than once",
+		unit:        unitCount,
+	},
+	"timeouts": label{
+		description: "major timeouts of requests of given RPC type",
+		unit:        unitCount,
+	},
+	"rtt_ms": label{
+		description: "accumulated round trip time of requests of given RPC type, from transmission to the reply",
+		unit:        unitMilliseconds,
+	},
+	"execute_ms": label{
+		description: "accumulated execution time of requests of given RPC type, from submission to completion",
+		unit:        unitMilliseconds,
+	},
+}
+
+// nfsMount holds the statistics of a single NFS mount from mountstats
+type nfsMount struct {
+	device     string
+	mountPoint string
+	fstype     string
+	// bytes: normal/direct/server read/write bytes, read/write pages
+	bytes []uint64
+	ops   []nfsOp
+}
+
+// nfsOp holds the per-op statistics of a single RPC type
+type nfsOp struct {
+	name          string
+	ops           uint64
+	transmissions uint64
+	timeouts      uint64
+	rttMs         uint64
+	executeMs     uint64
+}
+
+func nfsStats(nss []plugin.Namespace, mounts []string) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "nfsStats")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(hostProc("self", "mountstats"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	nfsMounts, err := parseMountstats(f)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, mount := range selectNFSMounts(nfsMounts, mounts) {
+		tags := map[string]string{
+			"device": mount.device,
+			"fstype": mount.fstype,
+		}
+		for _, ns := range nss {
+			if ns[3].Value != "*" && ns[3].Value != mount.mountPoint {
+				continue
+			}
+			metricName := ns.Element(len(ns) - 1).Value
+			if len(ns) == 7 && ns[4].Value == "op" {
+				for _, op := range mount.ops {
+					if ns[5].Value != "*" && ns[5].Value != op.name {
+						continue
+					}
+					val, err := getNFSOpValue(&op, metricName)
+					if err != nil {
+						return nil, err
+					}
+					dyn := make([]plugin.NamespaceElement, len(ns))
+					copy(dyn, ns)
+					dyn[3].Value = mount.mountPoint
+					dyn[5].Value = op.name
+					results = append(results, plugin.Metric{
+						Namespace: dyn,
+						Data:      val,
+						Tags:      tags,
+						Timestamp: t,
+						Unit:      nfsOpLabels[metricName].unit,
+					})
+				}
+				continue
+			}
+			val, err := getNFSMountValue(&mount, metricName)
+			if err != nil {
+				return nil, err
+			}
+			dyn := make([]plugin.NamespaceElement, len(ns))
+			copy(dyn, ns)
+			dyn[3].Value = mount.mountPoint
+			results = append(results, plugin.Metric{
+				Namespace: dyn,
+				Data:      val,
+				Tags:      tags,
+				Timestamp: t,
+				Unit:      nfsLabels[metricName].unit,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// selectNFSMounts applies the mount_points configuration to NFS mounts;
+// NFS mounts are never physical, so unless mount points are listed
+// explicitly all of them are selected.
+func selectNFSMounts(nfsMounts []nfsMount, mounts []string) []nfsMount {
+	if strings.Contains(mounts[0], "physical") || strings.Contains(mounts[0], "all") {
+		return nfsMounts
+	}
+	selected := []nfsMount{}
+	for _, mount := range nfsMounts {
+		for _, mtpoint := range mounts {
+			if mount.mountPoint == mtpoint {
+				selected = append(selected, mount)
+			}
+		}
+	}
+	return selected
+}
+
+// parseMountstats parses the content of /proc/self/mountstats and returns the
+// statistics of NFS mounts, other file systems are skipped
+func parseMountstats(r io.Reader) ([]nfsMount, error) {
+	mounts := []nfsMount{}
+	var mount *nfsMount
+	inOps := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// device <device> mounted on <mount point> with fstype <fstype> [statvers=1.1]
+		if fields[0] == "device" {
+			mount, inOps = nil, false
+			if len(fields) < 8 || fields[2] != "mounted" || fields[5] != "with" {
+				return nil, fmt.Errorf("Invalid mountstats device line: %s", scanner.Text())
+			}
+			if !strings.HasPrefix(fields[7], "nfs") {
+				continue
+			}
+			mounts = append(mounts, nfsMount{
+				device:     unescapeMountPoint(fields[1]),
+				mountPoint: unescapeMountPoint(fields[4]),
+				fstype:     fields[7],
+			})
+			mount = &mounts[len(mounts)-1]
+			continue
+		}
+		if mount == nil {
+			continue
+		}
+		switch {
+		case fields[0] == "bytes:":
+			values, err := parseUints(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid mountstats bytes line for %s: %v", mount.mountPoint, err)
+			}
+			mount.bytes = values
+		case fields[0] == "per-op":
+			inOps = true
+		case inOps && strings.HasSuffix(fields[0], ":"):
+			// ops transmissions timeouts bytes_sent bytes_recv queue rtt execute [errors]
+			values, err := parseUints(fields[1:])
+			if err != nil || len(values) < 8 {
+				return nil, fmt.Errorf("Invalid mountstats per-op line for %s: %s", mount.mountPoint, scanner.Text())
+			}
+			mount.ops = append(mount.ops, nfsOp{
+				name:          strings.TrimSuffix(fields[0], ":"),
+				ops:           values[0],
+				transmissions: values[1],
+				timeouts:      values[2],
+				rttMs:         values[6],
+				executeMs:     values[7],
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+// unescapeMountPoint decodes the octal escapes, e.g. \040 for a space, the
+// kernel uses for whitespace and backslashes in mount points
+func unescapeMountPoint(path string) string {
+	if !strings.Contains(path, "\\") {
+		return path
+	}
+	buf := make([]byte, 0, len(path))
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 <= len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				buf = append(buf, byte(c))
+				i += 3
+				continue
+			}
+		}
+		buf = append(buf, path[i])
+	}
+	return string(buf)
+}
+
+func parseUints(fields []string) ([]uint64, error) {
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (m *nfsMount) byteCounter(idx int) uint64 {
+	if idx < len(m.bytes) {
+		return m.bytes[idx]
+	}
+	return 0
+}
+
+func getNFSMountValue(mount *nfsMount, name string) (uint64, error) {
+	switch name {
+	case "bytes_read":
+		return mount.byteCounter(0) + mount.byteCounter(2), nil
+	case "bytes_written":
+		return mount.byteCounter(1) + mount.byteCounter(3), nil
+	case "server_bytes_read":
+		return mount.byteCounter(4), nil
+	case "server_bytes_written":
+		return mount.byteCounter(5), nil
+	case "ops", "retransmissions", "timeouts":
+		var total uint64
+		for _, op := range mount.ops {
+			val, _ := getNFSOpValue(&op, name)
+			total += val
+		}
+		return total, nil
+	default:
+		return 0, fmt.Errorf("Requested NFS statistic %s is not available", name)
+	}
+}
+
+func getNFSOpValue(op *nfsOp, name string) (uint64, error) {
+	switch name {
+	case "ops":
+		return op.ops, nil
+	case "retransmissions":
+		if op.transmissions < op.ops {
+			return 0, nil
+		}
+		return op.transmissions - op.ops, nil
+	case "timeouts":
+		return op.timeouts, nil
+	case "rtt_ms":
+		return op.rttMs, nil
+	case "execute_ms":
+		return op.executeMs, nil
+	default:
+		return 0, fmt.Errorf("Requested NFS per-op statistic %s is not available", name)
+	}
+}
+
+func getNFSMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getNFSMetricTypes")
+	mts := []plugin.Metric{}
+	for name, label := range nfsLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace: plugin.NewNamespace("intel", "psutil", "nfs").
+				AddDynamicElement("mount_point", "Mount Point").
+				AddStaticElement(name),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
+	for name, label := range nfsOpLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace: plugin.NewNamespace("intel", "psutil", "nfs").
+				AddDynamicElement("mount_point", "Mount Point").
+				AddStaticElement("op").
+				AddDynamicElement("op_name", "RPC request type").
+				AddStaticElement(name),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
+	return mts
+}
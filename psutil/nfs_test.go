@@ -0,0 +1,103 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseMountstats(t *testing.T) {
+	Convey("Parse /proc/self/mountstats", t, func() {
+		f, err := os.Open("testdata/mountstats")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		mounts, err := parseMountstats(f)
+		So(err, ShouldBeNil)
+		So(mounts, ShouldHaveLength, 3)
+
+		Convey("NFSv4 mount over TCP", func() {
+			m := mounts[0]
+			So(m.device, ShouldEqual, "nfs01:/export/build")
+			So(m.mountPoint, ShouldEqual, "/mnt/build")
+			So(m.fstype, ShouldEqual, "nfs4")
+			So(m.ops, ShouldHaveLength, 5)
+
+			val, err := getNFSMountValue(&m, "bytes_read")
+			So(err, ShouldBeNil)
+			So(val, ShouldEqual, uint64(1207959552+4096))
+			val, _ = getNFSMountValue(&m, "server_bytes_written")
+			So(val, ShouldEqual, uint64(536879104))
+			val, _ = getNFSMountValue(&m, "ops")
+			So(val, ShouldEqual, uint64(1+1152+512+16+30))
+			val, _ = getNFSMountValue(&m, "retransmissions")
+			So(val, ShouldEqual, uint64(8))
+			val, _ = getNFSMountValue(&m, "timeouts")
+			So(val, ShouldEqual, uint64(2))
+
+			read := m.ops[1]
+			So(read.name, ShouldEqual, "READ")
+			val, _ = getNFSOpValue(&read, "rtt_ms")
+			So(val, ShouldEqual, uint64(9216))
+			val, _ = getNFSOpValue(&read, "execute_ms")
+			So(val, ShouldEqual, uint64(9580))
+		})
+		Convey("NFSv3 mount over UDP", func() {
+			m := mounts[1]
+			So(m.mountPoint, ShouldEqual, "/home")
+			So(m.fstype, ShouldEqual, "nfs")
+			So(m.ops, ShouldHaveLength, 3)
+			val, _ := getNFSOpValue(&m.ops[1], "retransmissions")
+			So(val, ShouldEqual, uint64(4))
+			val, _ = getNFSOpValue(&m.ops[1], "timeouts")
+			So(val, ShouldEqual, uint64(4))
+		})
+		Convey("mount point with an escaped space", func() {
+			m := mounts[2]
+			So(m.device, ShouldEqual, "nfs02:/export/team share")
+			So(m.mountPoint, ShouldEqual, "/mnt/team share")
+			So(m.ops, ShouldHaveLength, 2)
+		})
+		Convey("mount point selection", func() {
+			So(selectNFSMounts(mounts, []string{"physical"}), ShouldHaveLength, 3)
+			So(selectNFSMounts(mounts, []string{"all"}), ShouldHaveLength, 3)
+			selected := selectNFSMounts(mounts, []string{"/", "/home"})
+			So(selected, ShouldHaveLength, 1)
+			So(selected[0].mountPoint, ShouldEqual, "/home")
+			selected = selectNFSMounts(mounts, []string{"/mnt/team share"})
+			So(selected, ShouldHaveLength, 1)
+			So(selected[0].device, ShouldEqual, "nfs02:/export/team share")
+		})
+	})
+}
+
+func TestUnescapeMountPoint(t *testing.T) {
+	Convey("Decode octal escapes of mount points", t, func() {
+		So(unescapeMountPoint("/mnt/build"), ShouldEqual, "/mnt/build")
+		So(unescapeMountPoint(`/mnt/a\040b`), ShouldEqual, "/mnt/a b")
+		So(unescapeMountPoint(`/mnt/tab\011and\134slash`), ShouldEqual, "/mnt/tab\tand\\slash")
+		So(unescapeMountPoint(`/mnt/trailing\04`), ShouldEqual, `/mnt/trailing\04`)
+	})
+}
@@ -0,0 +1,228 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+var numaLabels = map[string]label{
+	"mem_total": label{
+		description: "total memory of the node",
+		unit:        unitBytes,
+	},
+	"mem_free": label{
+		description: "free memory of the node",
+		unit:        unitBytes,
+	},
+	"mem_used": label{
+		description: "memory of the node in use",
+		unit:        unitBytes,
+	},
+	"file_pages": label{
+		description: "memory of the node used by the page cache",
+		unit:        unitBytes,
+	},
+	"anon_pages": label{
+		description: "memory of the node used by anonymous pages",
+		unit:        unitBytes,
+	},
+	"numa_hit": label{
+		description: "number of pages allocated on the node as intended since boot",
+		unit:        unitCount,
+	},
+	"numa_miss": label{
+		description: "number of pages allocated on the node although another node was preferred since boot",
+		unit:        unitCount,
+	},
+	"numa_foreign": label{
+		description: "number of pages intended for the node but allocated on another node since boot",
+		unit:        unitCount,
+	},
+	"local_node": label{
+		description: "number of pages allocated on the node by a process running on it since boot",
+		unit:        unitCount,
+	},
+	"other_node": label{
+		description: "number of pages allocated on the node by a process running on another node since boot",
+		unit:        unitCount,
+	},
+}
+
+// numaMeminfoFields maps metrics to the fields of a node meminfo they are
+// read from
+var numaMeminfoFields = map[string]string{
+	"mem_total":  "MemTotal",
+	"mem_free":   "MemFree",
+	"mem_used":   "MemUsed",
+	"file_pages": "FilePages",
+	"anon_pages": "AnonPages",
+}
+
+// numaNode holds the memory statistics of a NUMA node, keyed by metric name
+type numaNode struct {
+	name   string
+	cpus   string
+	values map[string]uint64
+}
+
+func numaStats(nss []plugin.Namespace) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "numaStats")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	nodes, err := getNUMANodes()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, ns := range nss {
+		metricName := ns.Element(len(ns) - 1).Value
+		if _, ok := numaLabels[metricName]; !ok {
+			return nil, fmt.Errorf("Requested NUMA statistic %s is not available", metricName)
+		}
+		for _, node := range nodes {
+			if ns[3].Value != "*" && ns[3].Value != node.name {
+				continue
+			}
+			value, ok := node.values[metricName]
+			if !ok {
+				continue
+			}
+			dyn := make([]plugin.NamespaceElement, len(ns))
+			copy(dyn, ns)
+			dyn[3].Value = node.name
+			results = append(results, plugin.Metric{
+				Namespace: dyn,
+				Data:      value,
+				Tags:      map[string]string{"cpus": node.cpus},
+				Timestamp: t,
+				Unit:      numaLabels[metricName].unit,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// getNUMANodes reads meminfo and numastat of each node of
+// /sys/devices/system/node, sorted by node number
+func getNUMANodes() ([]numaNode, error) {
+	dirs, err := filepath.Glob(hostSys("devices", "system", "node", "node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	// paths only differ by the node number
+	sort.Sort(byCPUNumber(dirs))
+	nodes := []numaNode{}
+	for _, dir := range dirs {
+		node := numaNode{
+			name:   filepath.Base(dir),
+			cpus:   readSysfsString(filepath.Join(dir, "cpulist")),
+			values: map[string]uint64{},
+		}
+		if err := readNUMAFile(filepath.Join(dir, "meminfo"), node.values, parseNUMAMeminfo); err != nil {
+			return nil, err
+		}
+		if err := readNUMAFile(filepath.Join(dir, "numastat"), node.values, parseNUMAStat); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func readNUMAFile(path string, values map[string]uint64, parse func(io.Reader, map[string]uint64) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return parse(f, values)
+}
+
+// parseNUMAMeminfo parses the meminfo of a node, e.g.
+// "Node 0 MemTotal:  16318744 kB", into values keyed by metric name
+func parseNUMAMeminfo(r io.Reader, values map[string]uint64) error {
+	fields, err := parseMeminfo(r)
+	if err != nil {
+		return err
+	}
+	for metric, name := range numaMeminfoFields {
+		for _, field := range fields {
+			if field.name == name {
+				values[metric] = field.value
+			}
+		}
+	}
+	return nil
+}
+
+// parseNUMAStat parses the numastat of a node, e.g. "numa_hit 4854929", into
+// values keyed by metric name
+func parseNUMAStat(r io.Reader, values map[string]uint64) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if _, ok := numaLabels[fields[0]]; !ok {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid %s value in numastat: %s", fields[0], fields[1])
+		}
+		values[fields[0]] = value
+	}
+	return scanner.Err()
+}
+
+func getNUMAMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getNUMAMetricTypes")
+	mts := []plugin.Metric{}
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	for k, label := range numaLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace: plugin.NewNamespace("intel", "psutil", "numa").
+				AddDynamicElement("node", "NUMA node (node0, node1, ...)").
+				AddStaticElement(k),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
+	return mts
+}
@@ -0,0 +1,56 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetNUMANodes(t *testing.T) {
+	Convey("Read NUMA node memory statistics", t, func() {
+		os.Setenv("HOST_SYS", "testdata/sys")
+		defer os.Unsetenv("HOST_SYS")
+
+		nodes, err := getNUMANodes()
+		So(err, ShouldBeNil)
+		So(len(nodes), ShouldEqual, 2)
+		So(nodes[0].name, ShouldEqual, "node0")
+		So(nodes[0].cpus, ShouldEqual, "0-1")
+		So(nodes[0].values, ShouldResemble, map[string]uint64{
+			"mem_total":    8159372 * 1024,
+			"mem_free":     412364 * 1024,
+			"mem_used":     7747008 * 1024,
+			"file_pages":   3910594 * 1024,
+			"anon_pages":   2820914 * 1024,
+			"numa_hit":     48549290,
+			"numa_miss":    1203,
+			"numa_foreign": 0,
+			"local_node":   48540001,
+			"other_node":   10492,
+		})
+		So(nodes[1].name, ShouldEqual, "node1")
+		So(nodes[1].values["numa_foreign"], ShouldEqual, uint64(1203))
+	})
+}
@@ -20,11 +20,15 @@ package psutil
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"time"
 
 	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	"github.com/shirou/gopsutil/host"
 
 	log "github.com/Sirupsen/logrus"
 )
@@ -39,24 +43,82 @@ type Psutil struct {
 // CollectMetrics returns metrics from gopsutil
 func (p *Psutil) CollectMetrics(mts []plugin.Metric) ([]plugin.Metric, error) {
 	loadReqs := []plugin.Namespace{}
+	hostReqs := []plugin.Namespace{}
 	cpuReqs := []plugin.Namespace{}
+	cpuFreqReqs := []plugin.Namespace{}
+	cpuIdleReqs := []plugin.Namespace{}
 	memReqs := []plugin.Namespace{}
+	meminfoReqs := []plugin.Namespace{}
+	numaReqs := []plugin.Namespace{}
+	hugepagesReqs := []plugin.Namespace{}
+	thpReqs := []plugin.Namespace{}
+	buddyinfoReqs := []plugin.Namespace{}
+	slabReqs := []plugin.Namespace{}
+	cgroupReqs := []plugin.Namespace{}
 	netReqs := []plugin.Namespace{}
 	diskReqs := []plugin.Namespace{}
+	diskioReqs := []plugin.Namespace{}
+	mdraidReqs := []plugin.Namespace{}
+	nfsReqs := []plugin.Namespace{}
+	kernelReqs := []plugin.Namespace{}
+	interruptsReqs := []plugin.Namespace{}
+	softirqsReqs := []plugin.Namespace{}
+	schedstatReqs := []plugin.Namespace{}
+	// config of the first requested metric of each subsystem
+	configs := map[string]plugin.Config{}
 
 	for _, m := range mts {
 		ns := m.Namespace
+		if _, ok := configs[ns[2].Value]; !ok {
+			configs[ns[2].Value] = m.Config
+		}
 		switch ns[2].Value {
 		case "load":
 			loadReqs = append(loadReqs, ns)
+		case "host":
+			hostReqs = append(hostReqs, ns)
 		case "cpu":
-			cpuReqs = append(cpuReqs, ns)
+			if _, ok := cpuFreqLabels[ns.Element(len(ns)-1).Value]; ok {
+				cpuFreqReqs = append(cpuFreqReqs, ns)
+			} else {
+				cpuReqs = append(cpuReqs, ns)
+			}
+		case "cpuidle":
+			cpuIdleReqs = append(cpuIdleReqs, ns)
 		case "vm":
 			memReqs = append(memReqs, ns)
+		case "meminfo":
+			meminfoReqs = append(meminfoReqs, ns)
+		case "numa":
+			numaReqs = append(numaReqs, ns)
+		case "hugepages":
+			hugepagesReqs = append(hugepagesReqs, ns)
+		case "thp":
+			thpReqs = append(thpReqs, ns)
+		case "buddyinfo":
+			buddyinfoReqs = append(buddyinfoReqs, ns)
+		case "slab":
+			slabReqs = append(slabReqs, ns)
+		case "cgroup":
+			cgroupReqs = append(cgroupReqs, ns)
 		case "net":
 			netReqs = append(netReqs, ns)
 		case "disk":
 			diskReqs = append(diskReqs, ns)
+		case "diskio":
+			diskioReqs = append(diskioReqs, ns)
+		case "mdraid":
+			mdraidReqs = append(mdraidReqs, ns)
+		case "nfs":
+			nfsReqs = append(nfsReqs, ns)
+		case "kernel":
+			kernelReqs = append(kernelReqs, ns)
+		case "interrupts":
+			interruptsReqs = append(interruptsReqs, ns)
+		case "softirqs":
+			softirqsReqs = append(softirqsReqs, ns)
+		case "schedstat":
+			schedstatReqs = append(schedstatReqs, ns)
 		default:
 			return nil, fmt.Errorf("Requested metric %s does not match any known psutil metric", m.Namespace.String())
 		}
@@ -70,30 +132,173 @@ func (p *Psutil) CollectMetrics(mts []plugin.Metric) ([]plugin.Metric, error) {
 	}
 	metrics = append(metrics, loadMts...)
 
-	cpuMts, err := cpuTimes(cpuReqs)
+	// host facts are read once for the host metrics and the host_tags option
+	hostTags := getHostTags(mts[0].Config)
+	var info *host.InfoStat
+	if len(hostReqs) > 0 || hostTags {
+		info, err = host.Info()
+		if err != nil {
+			return nil, err
+		}
+	}
+	hostMts, err := hostInfo(hostReqs, info)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, hostMts...)
+
+	// cpu times and kernel counters come from a single read of /proc/stat
+	var stat *procStat
+	if len(cpuReqs) > 0 || len(kernelReqs) > 0 {
+		stat, err = readProcStat()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cpuUnits, err := getUnitConversion(configs["cpu"])
+	if err != nil {
+		return nil, err
+	}
+	cpuMts, err := cpuTimes(cpuReqs, stat, cpuUnits)
 	if err != nil {
 		return nil, err
 	}
 	metrics = append(metrics, cpuMts...)
 
-	memMts, err := virtualMemory(memReqs)
+	kernelMts, err := kernelStats(kernelReqs, stat)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, kernelMts...)
+
+	interruptsMts, err := interrupts(interruptsReqs, configs["interrupts"])
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, interruptsMts...)
+
+	softirqsMts, err := softirqs(softirqsReqs)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, softirqsMts...)
+
+	schedstatMts, err := schedstats(schedstatReqs)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, schedstatMts...)
+
+	cpuFreqMts, err := cpuFrequencies(cpuFreqReqs)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, cpuFreqMts...)
+
+	cpuIdleMts, err := cpuIdleStats(cpuIdleReqs)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, cpuIdleMts...)
+
+	memUnits, err := getUnitConversion(configs["vm"])
+	if err != nil {
+		return nil, err
+	}
+	memMts, err := virtualMemory(memReqs, memUnits)
 	if err != nil {
 		return nil, err
 	}
 	metrics = append(metrics, memMts...)
 
-	netMts, err := netIOCounters(netReqs)
+	meminfoMts, err := meminfo(meminfoReqs, configs["meminfo"])
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, meminfoMts...)
+
+	numaMts, err := numaStats(numaReqs)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, numaMts...)
+
+	hugepagesMts, err := hugepages(hugepagesReqs)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, hugepagesMts...)
+
+	thpMts, err := transparentHugepages(thpReqs)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, thpMts...)
+
+	buddyinfoMts, err := buddyinfo(buddyinfoReqs)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, buddyinfoMts...)
+
+	slabMts, err := slabStats(slabReqs, configs["slab"])
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, slabMts...)
+
+	cgroupMts, err := cgroupStats(cgroupReqs, configs["cgroup"])
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, cgroupMts...)
+
+	netUnits, err := getUnitConversion(configs["net"])
+	if err != nil {
+		return nil, err
+	}
+	netMts, err := netIOCounters(netReqs, netUnits)
 	if err != nil {
 		return nil, err
 	}
 	metrics = append(metrics, netMts...)
-	mounts := getMountpoints(mts[0].Config)
-	diskMts, err := getDiskUsageMetrics(diskReqs, mounts)
+	diskUnits, err := getUnitConversion(configs["disk"])
+	if err != nil {
+		return nil, err
+	}
+	diskMts, err := getDiskUsageMetrics(diskReqs, getMountpoints(configs["disk"]), getProbeTimeout(configs["disk"]), diskUnits)
 	if err != nil {
 		return nil, err
 	}
 	metrics = append(metrics, diskMts...)
 
+	diskioUnits, err := getUnitConversion(configs["diskio"])
+	if err != nil {
+		return nil, err
+	}
+	diskioMts, err := diskIOCounters(diskioReqs, configs["diskio"], diskioUnits)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, diskioMts...)
+
+	mdraidMts, err := mdraidStats(mdraidReqs)
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, mdraidMts...)
+
+	nfsMts, err := nfsStats(nfsReqs, getMountpoints(configs["nfs"]))
+	if err != nil {
+		return nil, err
+	}
+	metrics = append(metrics, nfsMts...)
+
+	if hostTags {
+		addHostTags(metrics, hostFacts(info))
+	}
+
 	return metrics, nil
 }
 
@@ -102,12 +307,27 @@ func (p *Psutil) GetMetricTypes(_ plugin.Config) ([]plugin.Metric, error) {
 	mts := []plugin.Metric{}
 
 	mts = append(mts, getLoadAvgMetricTypes()...)
+	mts = append(mts, getHostMetricTypes()...)
 	mts_, err := getCPUTimesMetricTypes()
 	if err != nil {
 		return nil, err
 	}
 	mts = append(mts, mts_...)
+	mts = append(mts, getCPUFreqMetricTypes()...)
+	mts = append(mts, getCPUIdleMetricTypes()...)
+	mts = append(mts, getCPUGroupMetricTypes()...)
+	mts = append(mts, getKernelMetricTypes()...)
+	mts = append(mts, getInterruptsMetricTypes()...)
+	mts = append(mts, getSoftirqsMetricTypes()...)
+	mts = append(mts, getSchedstatMetricTypes()...)
 	mts = append(mts, getVirtualMemoryMetricTypes()...)
+	mts = append(mts, getMeminfoMetricTypes()...)
+	mts = append(mts, getNUMAMetricTypes()...)
+	mts = append(mts, getHugepagesMetricTypes()...)
+	mts = append(mts, getBuddyinfoMetricTypes()...)
+	mts = append(mts, getSlabMetricTypes()...)
+	mts = append(mts, getCgroupMetricTypes()...)
+	mts = append(mts, getCgroupPressureMetricTypes()...)
 
 	mts_, err = getNetIOCounterMetricTypes()
 	if err != nil {
@@ -115,6 +335,9 @@ func (p *Psutil) GetMetricTypes(_ plugin.Config) ([]plugin.Metric, error) {
 	}
 	mts = append(mts, mts_...)
 	mts = append(mts, getDiskUsageMetricTypes()...)
+	mts = append(mts, getDiskIOMetricTypes()...)
+	mts = append(mts, getMdraidMetricTypes()...)
+	mts = append(mts, getNFSMetricTypes()...)
 
 	return mts, nil
 }
@@ -122,8 +345,38 @@ func (p *Psutil) GetMetricTypes(_ plugin.Config) ([]plugin.Metric, error) {
 //GetConfigPolicy returns a ConfigPolicy
 func (p *Psutil) GetConfigPolicy() (plugin.ConfigPolicy, error) {
 	c := plugin.NewConfigPolicy()
+	c.AddNewBoolRule([]string{"intel", "psutil"},
+		"host_tags", false, plugin.SetDefaultBool(false))
 	c.AddNewStringRule([]string{"intel", "psutil", "disk"},
 		"mount_points", false)
+	c.AddNewIntRule([]string{"intel", "psutil", "disk"},
+		"probe_timeout", false, plugin.SetDefaultInt(defaultProbeTimeout))
+	c.AddNewBoolRule([]string{"intel", "psutil", "diskio"},
+		"friendly_names", false, plugin.SetDefaultBool(false))
+	c.AddNewStringRule([]string{"intel", "psutil", "nfs"},
+		"mount_points", false)
+	c.AddNewStringRule([]string{"intel", "psutil", "interrupts"},
+		"irqs", false)
+	c.AddNewStringRule([]string{"intel", "psutil", "interrupts"},
+		"devices", false)
+	c.AddNewBoolRule([]string{"intel", "psutil", "interrupts"},
+		"totals_only", false, plugin.SetDefaultBool(false))
+	for _, ns := range []string{"vm", "disk", "diskio", "net"} {
+		c.AddNewStringRule([]string{"intel", "psutil", ns},
+			"byte_unit", false, plugin.SetDefaultString(unitBytes))
+	}
+	c.AddNewStringRule([]string{"intel", "psutil", "cpu"},
+		"time_unit", false, plugin.SetDefaultString(unitSeconds))
+	c.AddNewStringRule([]string{"intel", "psutil", "meminfo"},
+		"fields", false)
+	c.AddNewIntRule([]string{"intel", "psutil", "slab"},
+		"top", false, plugin.SetDefaultInt(defaultSlabTop))
+	c.AddNewStringRule([]string{"intel", "psutil", "slab"},
+		"caches", false)
+	c.AddNewStringRule([]string{"intel", "psutil", "cgroup"},
+		"include", false)
+	c.AddNewStringRule([]string{"intel", "psutil", "cgroup"},
+		"exclude", false)
 	return *c, nil
 }
 
@@ -138,6 +391,49 @@ func getMountpoints(cfg plugin.Config) []string {
 	return []string{"physical"}
 }
 
+// defaultProbeTimeout is the time in milliseconds given to statfs of a mount
+// point before it is reported as unresponsive
+const defaultProbeTimeout = 2000
+
+func getProbeTimeout(cfg plugin.Config) time.Duration {
+	if timeout, err := cfg.GetInt("probe_timeout"); err == nil && timeout > 0 {
+		return time.Duration(timeout) * time.Millisecond
+	}
+	return defaultProbeTimeout * time.Millisecond
+}
+
+// Units of metrics; every metric reports one of them so that the same
+// quantity is always expressed the same way across namespaces
+const (
+	unitBytes          = "B"
+	unitBytesPerSecond = "B/s"
+	unitPercent        = "%"
+	// unitRatio is a fraction between 0 and 1
+	unitRatio        = "ratio"
+	unitSeconds      = "s"
+	unitMilliseconds = "ms"
+	unitMicroseconds = "us"
+	unitNanoseconds  = "ns"
+	unitMegahertz    = "MHz"
+	unitPerSecond    = "1/s"
+	// unitCount is a number of things (pages, devices, ...) or events
+	// (interrupts, retransmissions, ...)
+	unitCount = "count"
+	// unitBoolean is 1 for true and 0 for false
+	unitBoolean    = "bool"
+	unitIdentifier = "id"
+	unitText       = "text"
+	// load averages over 1, 5 and 15 minutes
+	unitLoad1  = "Load/1M"
+	unitLoad5  = "Load/5M"
+	unitLoad15 = "Load/15M"
+	// units byte values and times can be converted to
+	unitKibibytes = "KiB"
+	unitMebibytes = "MiB"
+	unitGibibytes = "GiB"
+	unitJiffies   = "jiffies"
+)
+
 type label struct {
 	description string
 	unit        string
@@ -147,3 +443,52 @@ func timeSpent(start time.Time, name string) {
 	elapsed := time.Since(start)
 	log.Debugf("%s took %s", name, elapsed)
 }
+
+// counterSamples keeps the previous sample of counters turned into
+// per-second rates, keyed by metric namespace and instance
+var counterSamples = struct {
+	sync.Mutex
+	last map[string]counterSample
+}{last: map[string]counterSample{}}
+
+type counterSample struct {
+	value float64
+	at    time.Time
+}
+
+// counterRate returns the per-second rate of a counter since its previous
+// sample; there is no rate on the first sample or after the counter was reset
+func counterRate(key string, value float64, at time.Time) (float64, bool) {
+	counterSamples.Lock()
+	defer counterSamples.Unlock()
+	last, ok := counterSamples.last[key]
+	counterSamples.last[key] = counterSample{value: value, at: at}
+	if !ok || value < last.value || !at.After(last.at) {
+		return 0, false
+	}
+	return (value - last.value) / at.Sub(last.at).Seconds(), true
+}
+
+// hostProc, hostSys and hostDev build paths into /proc, /sys and /dev,
+// honouring the HOST_PROC, HOST_SYS and HOST_DEV environment variables the
+// same way gopsutil does, so that files read directly by the plugin come from
+// the same tree as the ones read by gopsutil when running in a container.
+func hostProc(combineWith ...string) string {
+	return hostPath("HOST_PROC", "/proc", combineWith...)
+}
+
+func hostSys(combineWith ...string) string {
+	return hostPath("HOST_SYS", "/sys", combineWith...)
+}
+
+func hostDev(combineWith ...string) string {
+	return hostPath("HOST_DEV", "/dev", combineWith...)
+}
+
+func hostPath(key, dfault string, combineWith ...string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		value = dfault
+	}
+	return filepath.Join(append([]string{value}, combineWith...)...)
+}
@@ -40,8 +40,23 @@ func TestPsutilGetMetricTypes(t *testing.T) {
 			So(err, ShouldBeNil)
 			So(metric_types, ShouldNotBeNil)
 			So(metric_types, ShouldNotBeEmpty)
-			//55 collectable metrics
-			So(len(metric_types), ShouldEqual, 55)
+			//170 collectable metrics plus socket and NUMA node aggregates
+			//and frequencies when cpufreq or /proc/cpuinfo reports them
+			//and idle states when a cpuidle driver is in use
+			//and cgroup pressure on cgroup v2 hosts
+			cpuFreq := 0
+			if cpuFreqSource() != "" {
+				cpuFreq = 2 * len(cpuFreqLabels)
+			}
+			cpuIdle := 0
+			if cpuIdleDriver() != "" {
+				cpuIdle = 2 * len(cpuIdleLabels)
+			}
+			cgroupPressure := 0
+			if cgroupV2Root() != "" {
+				cgroupPressure = len(cgroupPressureResources) * len(cgroupPressureKinds) * len(cgroupPressureLabels)
+			}
+			So(len(metric_types), ShouldEqual, 170+len(cpuGroups())*(len(cpuLabels)+len(cpuGroupLabels))+cpuFreq+cpuIdle+cgroupPressure)
 		})
 	})
 
@@ -48,3 +48,43 @@ func TestPSUtilPlugin(t *testing.T) {
 		})
 	})
 }
+
+func TestMetricTypesUnitsAndDescriptions(t *testing.T) {
+	Convey("Every advertised metric type", t, func() {
+		mts, err := NewPsutilCollector().GetMetricTypes(plugin.Config{})
+		So(err, ShouldBeNil)
+		So(mts, ShouldNotBeEmpty)
+		units := map[string]bool{}
+		for _, unit := range []string{
+			unitBytes, unitBytesPerSecond, unitPercent, unitRatio,
+			unitSeconds, unitMilliseconds, unitMicroseconds, unitNanoseconds,
+			unitMegahertz, unitPerSecond, unitCount, unitBoolean,
+			unitIdentifier, unitText, unitLoad1, unitLoad5, unitLoad15,
+			unitKibibytes, unitMebibytes, unitGibibytes, unitJiffies,
+		} {
+			units[unit] = true
+		}
+		noDescription, unknownUnit, dynamicLeaf := []string{}, []string{}, []string{}
+		for _, mt := range mts {
+			if mt.Description == "" {
+				noDescription = append(noDescription, mt.Namespace.String())
+			}
+			if mt.Namespace[len(mt.Namespace)-1].IsDynamic() {
+				dynamicLeaf = append(dynamicLeaf, mt.Namespace.String())
+			}
+			if !units[mt.Unit] {
+				unknownUnit = append(unknownUnit, mt.Namespace.String()+" ("+mt.Unit+")")
+			}
+		}
+		Convey("should have a description", func() {
+			So(noDescription, ShouldBeEmpty)
+		})
+		Convey("should have a unit of the vocabulary", func() {
+			So(unknownUnit, ShouldBeEmpty)
+		})
+		// snapd refuses to load plugins with namespaces ending in a wildcard
+		Convey("should end in a static element", func() {
+			So(dynamicLeaf, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,179 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+var schedstatLabels = map[string]label{
+	"run_time_ns": label{
+		description: "time spent by tasks running on the cpu since boot",
+		unit:        unitNanoseconds,
+	},
+	"wait_time_ns": label{
+		description: "time spent by tasks waiting on the run queue of the cpu since boot",
+		unit:        unitNanoseconds,
+	},
+	"timeslices": label{
+		description: "number of timeslices run on the cpu since boot",
+		unit:        unitCount,
+	},
+	"wait_ratio": label{
+		description: "time spent by tasks waiting on the run queue per second since the previous collection, i.e. the average number of waiting tasks",
+		unit:        unitCount,
+	},
+}
+
+// schedstat holds the run queue statistics of a cpu
+type schedstat struct {
+	runTime    uint64
+	waitTime   uint64
+	timeslices uint64
+}
+
+func schedstats(nss []plugin.Namespace) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "schedstats")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(hostProc("schedstat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stats, err := parseSchedstat(f)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, ns := range nss {
+		metricName := ns.Element(len(ns) - 1).Value
+		for _, name := range sortedSchedstatCPUNames(stats) {
+			if ns[3].Value != "*" && ns[3].Value != name {
+				continue
+			}
+			stat := stats[name]
+			dyn := make([]plugin.NamespaceElement, len(ns))
+			copy(dyn, ns)
+			dyn[3].Value = name
+			var data interface{}
+			switch metricName {
+			case "run_time_ns":
+				data = stat.runTime
+			case "wait_time_ns":
+				data = stat.waitTime
+			case "timeslices":
+				data = stat.timeslices
+			case "wait_ratio":
+				rate, ok := counterRate(plugin.Namespace(dyn).String(), float64(stat.waitTime), t)
+				if !ok {
+					// no rate until the counter was sampled twice
+					continue
+				}
+				data = rate / float64(time.Second)
+			default:
+				return nil, fmt.Errorf("Requested schedstat statistic %s is not available", metricName)
+			}
+			results = append(results, plugin.Metric{
+				Namespace: dyn,
+				Data:      data,
+				Tags:      cpuTags(name, nil),
+				Timestamp: t,
+				Unit:      schedstatLabels[metricName].unit,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// parseSchedstat parses the cpu lines of /proc/schedstat, e.g.
+// "cpu0 0 0 1234 567 890 123 4567890 12345 678", whose last three fields are
+// the run time, the run queue wait time and the number of timeslices; the
+// fields before them differ between versions of the format (9 counters
+// since version 15, 12 in version 14)
+func parseSchedstat(r io.Reader) (map[string]*schedstat, error) {
+	stats := map[string]*schedstat{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		last := fields[len(fields)-3:]
+		values := make([]uint64, 3)
+		for i := range values {
+			value, err := strconv.ParseUint(last[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid cpu line in /proc/schedstat: %s", strings.Join(fields, " "))
+			}
+			values[i] = value
+		}
+		stats[fields[0]] = &schedstat{
+			runTime:    values[0],
+			waitTime:   values[1],
+			timeslices: values[2],
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func sortedSchedstatCPUNames(stats map[string]*schedstat) []string {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Sort(byCPUNumber(names))
+	return names
+}
+
+func getSchedstatMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getSchedstatMetricTypes")
+	mts := []plugin.Metric{}
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	for k, label := range schedstatLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace: plugin.NewNamespace("intel", "psutil", "schedstat").
+				AddDynamicElement("cpu_id", "physical cpu id").
+				AddStaticElement(k),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
+	return mts
+}
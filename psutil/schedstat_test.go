@@ -0,0 +1,68 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseSchedstat(t *testing.T) {
+	Convey("Parse /proc/schedstat", t, func() {
+		f, err := os.Open("testdata/schedstat")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		stats, err := parseSchedstat(f)
+		So(err, ShouldBeNil)
+		So(sortedSchedstatCPUNames(stats), ShouldResemble, []string{"cpu0", "cpu1"})
+		So(*stats["cpu0"], ShouldResemble, schedstat{
+			runTime:    2563451240071,
+			waitTime:   120856720439,
+			timeslices: 41567385,
+		})
+		So(stats["cpu1"].waitTime, ShouldEqual, uint64(98765432100))
+	})
+
+	Convey("Parse /proc/schedstat version 14, with more counters per cpu", t, func() {
+		f, err := os.Open("testdata/schedstat_v14")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		stats, err := parseSchedstat(f)
+		So(err, ShouldBeNil)
+		So(sortedSchedstatCPUNames(stats), ShouldResemble, []string{"cpu0", "cpu1"})
+		So(*stats["cpu0"], ShouldResemble, schedstat{
+			runTime:    3471255810162,
+			waitTime:   210945328766,
+			timeslices: 1520388,
+		})
+	})
+
+	Convey("Parse invalid /proc/schedstat", t, func() {
+		_, err := parseSchedstat(strings.NewReader("cpu0 0 0 0 0 0 0 1 x 3\n"))
+		So(err, ShouldNotBeNil)
+	})
+}
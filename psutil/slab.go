@@ -0,0 +1,252 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// defaultSlabTop is the number of largest slab caches collected when the top
+// option is not set
+const defaultSlabTop = 10
+
+var slabLabels = map[string]label{
+	"active_objs": label{
+		description: "number of objects of the cache in use",
+		unit:        unitCount,
+	},
+	"total_objs": label{
+		description: "number of objects allocated for the cache, in use or not",
+		unit:        unitCount,
+	},
+	"active_bytes": label{
+		description: "memory taken by objects of the cache in use",
+		unit:        unitBytes,
+	},
+	"total_bytes": label{
+		description: "memory taken by all objects allocated for the cache",
+		unit:        unitBytes,
+	},
+}
+
+// slabCache is a single line of /proc/slabinfo
+type slabCache struct {
+	name       string
+	activeObjs uint64
+	totalObjs  uint64
+	objSize    uint64
+}
+
+func slabStats(nss []plugin.Namespace, cfg plugin.Config) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "slabStats")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	t := time.Now()
+	caches, err := readSlabinfo()
+	available := 1
+	if err != nil {
+		// /proc/slabinfo is only readable by root
+		if !os.IsPermission(err) {
+			return nil, err
+		}
+		log.Warnf("slab metrics are not available: %v", err)
+		available = 0
+	}
+
+	results := []plugin.Metric{}
+	selected := selectSlabCaches(caches, getSlabTop(cfg), getSlabCacheNames(cfg))
+
+	for _, ns := range nss {
+		metricName := ns.Element(len(ns) - 1).Value
+		if len(ns) == 4 && metricName == "available" {
+			results = append(results, plugin.Metric{
+				Namespace: ns,
+				Data:      available,
+				Timestamp: t,
+				Unit:      unitBoolean,
+			})
+			continue
+		}
+		candidates := selected
+		if ns[3].Value != "*" {
+			// explicitly requested caches are collected even if they are not
+			// among the largest ones
+			candidates = caches
+		}
+		for _, cache := range candidates {
+			if ns[3].Value != "*" && ns[3].Value != cache.name {
+				continue
+			}
+			val, err := getSlabValue(&cache, metricName)
+			if err != nil {
+				return nil, err
+			}
+			dyn := make([]plugin.NamespaceElement, len(ns))
+			copy(dyn, ns)
+			dyn[3].Value = cache.name
+			results = append(results, plugin.Metric{
+				Namespace: dyn,
+				Data:      val,
+				Tags:      map[string]string{"object_size": strconv.FormatUint(cache.objSize, 10)},
+				Timestamp: t,
+				Unit:      slabLabels[metricName].unit,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+func readSlabinfo() ([]slabCache, error) {
+	f, err := os.Open(hostProc("slabinfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseSlabinfo(f)
+}
+
+// parseSlabinfo parses /proc/slabinfo version 2.x, whose lines start with the
+// cache name followed by the numbers of active and total objects and the
+// object size, e.g. "dentry  183183  185325  192  21  1 : tunables ..."
+func parseSlabinfo(r io.Reader) ([]slabCache, error) {
+	caches := []slabCache{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "slabinfo") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		values := make([]uint64, 3)
+		for i := range values {
+			value, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid line in /proc/slabinfo: %s", line)
+			}
+			values[i] = value
+		}
+		caches = append(caches, slabCache{
+			name:       fields[0],
+			activeObjs: values[0],
+			totalObjs:  values[1],
+			objSize:    values[2],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return caches, nil
+}
+
+// selectSlabCaches returns the top largest caches by total size, followed by
+// the named caches which are not among them
+func selectSlabCaches(caches []slabCache, top int, names map[string]bool) []slabCache {
+	sorted := make([]slabCache, len(caches))
+	copy(sorted, caches)
+	sort.Stable(bySlabSize(sorted))
+	selected := []slabCache{}
+	for i, cache := range sorted {
+		if i < top || names[cache.name] {
+			selected = append(selected, cache)
+		}
+	}
+	return selected
+}
+
+func (c *slabCache) totalBytes() uint64 {
+	return c.totalObjs * c.objSize
+}
+
+// bySlabSize sorts caches from the largest to the smallest
+type bySlabSize []slabCache
+
+func (s bySlabSize) Len() int           { return len(s) }
+func (s bySlabSize) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s bySlabSize) Less(i, j int) bool { return s[i].totalBytes() > s[j].totalBytes() }
+
+func getSlabValue(cache *slabCache, name string) (uint64, error) {
+	switch name {
+	case "active_objs":
+		return cache.activeObjs, nil
+	case "total_objs":
+		return cache.totalObjs, nil
+	case "active_bytes":
+		return cache.activeObjs * cache.objSize, nil
+	case "total_bytes":
+		return cache.totalBytes(), nil
+	default:
+		return 0, fmt.Errorf("Requested slab statistic %s is not available", name)
+	}
+}
+
+func getSlabTop(cfg plugin.Config) int {
+	if top, err := cfg.GetInt("top"); err == nil && top >= 0 {
+		return int(top)
+	}
+	return defaultSlabTop
+}
+
+func getSlabCacheNames(cfg plugin.Config) map[string]bool {
+	names := map[string]bool{}
+	if caches, err := cfg.GetString("caches"); err == nil && caches != "" {
+		for _, name := range strings.Split(caches, "|") {
+			names[strings.TrimSpace(name)] = true
+		}
+	}
+	return names
+}
+
+func getSlabMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getSlabMetricTypes")
+	mts := []plugin.Metric{}
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	for k, label := range slabLabels {
+		mts = append(mts, plugin.Metric{
+			Namespace: plugin.NewNamespace("intel", "psutil", "slab").
+				AddDynamicElement("cache", "slab cache name (dentry, inode_cache, kmalloc-64, ...)").
+				AddStaticElement(k),
+			Description: label.description,
+			Unit:        label.unit,
+		})
+	}
+	mts = append(mts, plugin.Metric{
+		Namespace:   plugin.NewNamespace("intel", "psutil", "slab", "available"),
+		Description: "1 if /proc/slabinfo could be read, 0 if the plugin lacks the permission to read it",
+		Unit:        unitBoolean,
+	})
+	return mts
+}
@@ -0,0 +1,61 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseSlabinfo(t *testing.T) {
+	Convey("Parse /proc/slabinfo", t, func() {
+		f, err := os.Open("testdata/slabinfo")
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		caches, err := parseSlabinfo(f)
+		So(err, ShouldBeNil)
+		So(len(caches), ShouldEqual, 6)
+		So(caches[1], ShouldResemble, slabCache{
+			name:       "dentry",
+			activeObjs: 183183,
+			totalObjs:  185325,
+			objSize:    192,
+		})
+
+		Convey("and select the largest caches", func() {
+			selected := selectSlabCaches(caches, 2, map[string]bool{})
+			So(len(selected), ShouldEqual, 2)
+			So(selected[0].name, ShouldEqual, "ext4_inode_cache")
+			So(selected[1].name, ShouldEqual, "buffer_head")
+		})
+
+		Convey("together with named caches", func() {
+			selected := selectSlabCaches(caches, 1, map[string]bool{"nf_conntrack": true, "ext4_inode_cache": true})
+			So(len(selected), ShouldEqual, 2)
+			So(selected[0].name, ShouldEqual, "ext4_inode_cache")
+			So(selected[1].name, ShouldEqual, "nf_conntrack")
+		})
+	})
+}
@@ -0,0 +1,133 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+func softirqs(nss []plugin.Namespace) ([]plugin.Metric, error) {
+	defer timeSpent(time.Now(), "softirqs")
+	if len(nss) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(hostProc("softirqs"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// /proc/softirqs has the same layout as /proc/interrupts, with one line
+	// per softirq type and no description
+	types, cpus, err := parseInterrupts(f)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []plugin.Metric{}
+	t := time.Now()
+
+	for _, ns := range nss {
+		if len(ns) != 6 || (ns[5].Value != "count" && ns[5].Value != "per_sec") {
+			return nil, fmt.Errorf("Requested softirqs statistic %s is not available", strings.Join(ns.Strings()[3:], "/"))
+		}
+		rate := ns[5].Value == "per_sec"
+		for _, softirq := range types {
+			if ns[3].Value != "*" && ns[3].Value != softirq.name {
+				continue
+			}
+			counts := map[string]uint64{"total": softirq.total}
+			names := []string{"total"}
+			if ns[4].Value != "total" {
+				counts, names = softirq.counts, cpus
+			}
+			for _, name := range names {
+				count, ok := counts[name]
+				if !ok || (ns[4].Value != "*" && ns[4].Value != name) {
+					continue
+				}
+				dyn := make([]plugin.NamespaceElement, len(ns))
+				copy(dyn, ns)
+				dyn[3].Value = softirq.name
+				dyn[4].Value = name
+				metric := plugin.Metric{
+					Namespace: dyn,
+					Data:      count,
+					Timestamp: t,
+					Unit:      unitCount,
+				}
+				if name != "total" {
+					metric.Tags = cpuTags(name, nil)
+				}
+				if rate {
+					perSec, ok := counterRate(plugin.Namespace(dyn).String(), float64(count), t)
+					if !ok {
+						// no rate until the counter was sampled twice
+						continue
+					}
+					metric.Data = perSec
+					metric.Unit = unitPerSecond
+				}
+				results = append(results, metric)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func getSoftirqsMetricTypes() []plugin.Metric {
+	defer timeSpent(time.Now(), "getSoftirqsMetricTypes")
+	mts := []plugin.Metric{}
+	if runtime.GOOS != "linux" {
+		return mts
+	}
+	perCPU := plugin.NewNamespace("intel", "psutil", "softirqs").
+		AddDynamicElement("type", "softirq type (HI, TIMER, NET_TX, NET_RX, BLOCK, ...)").
+		AddDynamicElement("cpu_id", "physical cpu id")
+	total := plugin.NewNamespace("intel", "psutil", "softirqs").
+		AddDynamicElement("type", "softirq type (HI, TIMER, NET_TX, NET_RX, BLOCK, ...)").
+		AddStaticElement("total")
+	mts = append(mts, plugin.Metric{
+		Namespace:   append(plugin.Namespace{}, perCPU...).AddStaticElement("count"),
+		Description: "number of softirqs of given type handled by given cpu since boot",
+		Unit:        unitCount,
+	})
+	mts = append(mts, plugin.Metric{
+		Namespace:   append(plugin.Namespace{}, total...).AddStaticElement("count"),
+		Description: "number of softirqs of given type handled by all cpus since boot",
+		Unit:        unitCount,
+	})
+	mts = append(mts, plugin.Metric{
+		Namespace:   append(plugin.Namespace{}, perCPU...).AddStaticElement("per_sec"),
+		Description: "softirqs of given type handled per second by given cpu since the previous collection",
+		Unit:        unitPerSecond,
+	})
+	mts = append(mts, plugin.Metric{
+		Namespace:   append(plugin.Namespace{}, total...).AddStaticElement("per_sec"),
+		Description: "softirqs of given type handled per second by all cpus since the previous collection",
+		Unit:        unitPerSecond,
+	})
+	return mts
+}
@@ -0,0 +1,91 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package psutil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSoftirqs(t *testing.T) {
+	Convey("Collect softirqs from /proc/softirqs", t, func() {
+		os.Setenv("HOST_PROC", "testdata")
+		defer os.Unsetenv("HOST_PROC")
+		counterSamples.Lock()
+		counterSamples.last = map[string]counterSample{}
+		counterSamples.Unlock()
+
+		Convey("per cpu", func() {
+			metrics, err := softirqs([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "softirqs", "NET_RX", "*", "count"),
+			})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 2)
+			So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"intel", "psutil", "softirqs", "NET_RX", "cpu0", "count"})
+			So(metrics[0].Data, ShouldEqual, uint64(20356))
+			So(metrics[1].Data, ShouldEqual, uint64(18830))
+			So(metrics[1].Unit, ShouldEqual, unitCount)
+		})
+
+		Convey("over all cpus", func() {
+			metrics, err := softirqs([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "softirqs", "*", "total", "count"),
+			})
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 10)
+			So(metrics[1].Namespace.Strings(), ShouldResemble, []string{"intel", "psutil", "softirqs", "TIMER", "total", "count"})
+			So(metrics[1].Data, ShouldEqual, uint64(483752+471025))
+			So(metrics[1].Tags, ShouldBeEmpty)
+		})
+
+		Convey("without a count or per_sec leaf", func() {
+			_, err := softirqs([]plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "softirqs", "TIMER", "cpu0"),
+			})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("per second", func() {
+			nss := []plugin.Namespace{
+				plugin.NewNamespace("intel", "psutil", "softirqs", "TIMER", "cpu1", "per_sec"),
+				plugin.NewNamespace("intel", "psutil", "softirqs", "TIMER", "total", "per_sec"),
+			}
+			// no rate until the counters were sampled twice
+			metrics, err := softirqs(nss)
+			So(err, ShouldBeNil)
+			So(metrics, ShouldBeEmpty)
+
+			// the fixture does not change, so neither do the counters
+			metrics, err = softirqs(nss)
+			So(err, ShouldBeNil)
+			So(metrics, ShouldHaveLength, 2)
+			So(metrics[0].Namespace.Strings(), ShouldResemble, []string{"intel", "psutil", "softirqs", "TIMER", "cpu1", "per_sec"})
+			So(metrics[0].Data, ShouldEqual, 0.0)
+			So(metrics[0].Unit, ShouldEqual, unitPerSecond)
+			So(metrics[1].Namespace.Strings(), ShouldResemble, []string{"intel", "psutil", "softirqs", "TIMER", "total", "per_sec"})
+		})
+	})
+}
@@ -0,0 +1,103 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"fmt"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+)
+
+// byteUnits are the units byte values can be reported in, with the number of
+// bytes in each of them
+var byteUnits = map[string]float64{
+	unitBytes:     1,
+	unitKibibytes: 1 << 10,
+	unitMebibytes: 1 << 20,
+	unitGibibytes: 1 << 30,
+}
+
+// timeUnits are the units times can be reported in, with the number of them
+// in a second
+var timeUnits = map[string]float64{
+	unitSeconds:      1,
+	unitMilliseconds: 1000,
+	unitJiffies:      userHZ,
+}
+
+// unitConversion converts byte values and times of collected metrics to the
+// units configured with the byte_unit and time_unit options
+type unitConversion struct {
+	byteUnit string
+	timeUnit string
+}
+
+func getUnitConversion(cfg plugin.Config) (*unitConversion, error) {
+	conversion := &unitConversion{byteUnit: unitBytes, timeUnit: unitSeconds}
+	if byteUnit, err := cfg.GetString("byte_unit"); err == nil && byteUnit != "" {
+		if _, ok := byteUnits[byteUnit]; !ok {
+			return nil, fmt.Errorf("Invalid byte_unit %q, expected one of B, KiB, MiB, GiB", byteUnit)
+		}
+		conversion.byteUnit = byteUnit
+	}
+	if timeUnit, err := cfg.GetString("time_unit"); err == nil && timeUnit != "" {
+		if _, ok := timeUnits[timeUnit]; !ok {
+			return nil, fmt.Errorf("Invalid time_unit %q, expected one of s, ms, jiffies", timeUnit)
+		}
+		conversion.timeUnit = timeUnit
+	}
+	return conversion, nil
+}
+
+// convert updates data and unit of metrics reported in bytes or seconds.
+// Byte values stay integers in bytes and become floats in larger units.
+func (c *unitConversion) convert(metrics []plugin.Metric) []plugin.Metric {
+	for i := range metrics {
+		switch metrics[i].Unit {
+		case unitBytes:
+			if c.byteUnit == unitBytes {
+				continue
+			}
+			if value, ok := toFloat64(metrics[i].Data); ok {
+				metrics[i].Data = value / byteUnits[c.byteUnit]
+				metrics[i].Unit = c.byteUnit
+			}
+		case unitSeconds:
+			if c.timeUnit == unitSeconds {
+				continue
+			}
+			if value, ok := toFloat64(metrics[i].Data); ok {
+				metrics[i].Data = value * timeUnits[c.timeUnit]
+				metrics[i].Unit = c.timeUnit
+			}
+		}
+	}
+	return metrics
+}
+
+func toFloat64(data interface{}) (float64, bool) {
+	switch value := data.(type) {
+	case uint64:
+		return float64(value), true
+	case float64:
+		return value, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,73 @@
+//
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package psutil
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap-plugin-lib-go/v1/plugin"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnitConversion(t *testing.T) {
+	metrics := func() []plugin.Metric {
+		return []plugin.Metric{
+			{Data: uint64(3 << 20), Unit: unitBytes},
+			{Data: 1.5, Unit: unitSeconds},
+			{Data: 42.0, Unit: unitPercent},
+		}
+	}
+
+	Convey("Convert units of collected metrics", t, func() {
+		Convey("keeps bytes and seconds by default", func() {
+			units, err := getUnitConversion(plugin.Config{})
+			So(err, ShouldBeNil)
+			So(units.convert(metrics()), ShouldResemble, metrics())
+		})
+
+		Convey("to configured units", func() {
+			units, err := getUnitConversion(plugin.Config{"byte_unit": "MiB", "time_unit": "ms"})
+			So(err, ShouldBeNil)
+			converted := units.convert(metrics())
+			So(converted[0].Data, ShouldEqual, 3.0)
+			So(converted[0].Unit, ShouldEqual, unitMebibytes)
+			So(converted[1].Data, ShouldEqual, 1500.0)
+			So(converted[1].Unit, ShouldEqual, unitMilliseconds)
+			So(converted[2], ShouldResemble, metrics()[2])
+		})
+
+		Convey("to jiffies", func() {
+			units, err := getUnitConversion(plugin.Config{"time_unit": "jiffies"})
+			So(err, ShouldBeNil)
+			converted := units.convert(metrics())
+			So(converted[1].Data, ShouldEqual, 150.0)
+			So(converted[1].Unit, ShouldEqual, unitJiffies)
+		})
+
+		Convey("fails on unknown units", func() {
+			_, err := getUnitConversion(plugin.Config{"byte_unit": "MB"})
+			So(err, ShouldNotBeNil)
+			_, err = getUnitConversion(plugin.Config{"time_unit": "us"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}